@@ -12,8 +12,13 @@ import (
 
 // Config represents the application configuration
 type Config struct {
-	Server ServerConfig `yaml:"server"`
-	Logger LoggerConfig `yaml:"logger"`
+	Server       ServerConfig       `yaml:"server"`
+	Logger       LoggerConfig       `yaml:"logger"`
+	Storage      StorageConfig      `yaml:"storage"`
+	Export       ExportConfig       `yaml:"export"`
+	Scheduler    SchedulerConfig    `yaml:"scheduler"`
+	Subscription SubscriptionConfig `yaml:"subscription"`
+	Geo          GeoConfig          `yaml:"geo"`
 }
 
 // ServerConfig contains server-related configuration
@@ -35,6 +40,72 @@ type LoggerConfig struct {
 	Format        string `yaml:"format"`           // Log format: "text" or "json"
 }
 
+// StorageConfig contains task persistence configuration
+type StorageConfig struct {
+	Enabled       bool   `yaml:"enabled"`        // Whether to persist tasks and results to disk
+	Dir           string `yaml:"dir"`            // Directory for task files
+	RetentionDays int    `yaml:"retention_days"` // Days to keep finished tasks
+}
+
+// ExportConfig contains result export configuration
+type ExportConfig struct {
+	AllowServerWrite bool   `yaml:"allow_server_write"` // Whether export requests may save files on the server
+	Dir              string `yaml:"dir"`                // Directory for server-side export files
+}
+
+// SchedulerConfig contains scheduled job configuration
+type SchedulerConfig struct {
+	Enabled  bool   `yaml:"enabled"`   // Whether scheduled jobs are fired
+	JobsFile string `yaml:"jobs_file"` // File for persisting scheduled jobs
+}
+
+// GeoConfig contains IP geolocation configuration
+type GeoConfig struct {
+	CityDB       string `yaml:"city_db"`       // GeoLite2-City compatible .mmdb file
+	CountryDB    string `yaml:"country_db"`    // GeoLite2-Country compatible .mmdb file, used when city_db is empty
+	ASNDB        string `yaml:"asn_db"`        // GeoLite2-ASN compatible .mmdb file
+	ASNTable     string `yaml:"asn_table"`     // ASN category table merged over the bundled one
+	HTTPFallback bool   `yaml:"http_fallback"` // Query ip-api.com when the databases have no answer
+	CacheSize    int    `yaml:"cache_size"`    // Number of locations kept in the LRU cache
+}
+
+// SubscriptionConfig contains live subscription profiles served at /sub/{profile}.yaml
+type SubscriptionConfig struct {
+	Profiles []SubscriptionProfile `yaml:"profiles"`
+}
+
+// SubscriptionProfile describes which tested nodes a subscription serves and how they are grouped
+type SubscriptionProfile struct {
+	Name          string             `yaml:"name"`           // Profile name used in the URL
+	Token         string             `yaml:"token"`          // Optional access token, passed as ?token=
+	Source        string             `yaml:"source"`         // "latest" or "job:<job id or name>"
+	MaxLatency    int                `yaml:"max_latency"`    // Maximum latency in ms (0 = no limit)
+	MinDownload   float64            `yaml:"min_download"`   // Minimum download speed in MB/s
+	MinUpload     float64            `yaml:"min_upload"`     // Minimum upload speed in MB/s
+	RequireUnlock []string           `yaml:"require_unlock"` // Platforms every node must unlock
+	ASNTypes      []string           `yaml:"asn_types"`      // Exit ASN categories to serve (residential, datacenter, mobile, education)
+	SortBy        string             `yaml:"sort_by"`        // latency, download, upload, name
+	TopN          int                `yaml:"top_n"`          // Serve only the best N nodes (0 = all)
+	ResolveGeo    bool               `yaml:"resolve_geo"`    // Look up node locations for per-country groups
+	KeepNames     bool               `yaml:"keep_names"`     // Keep original node names for stable client selections
+	Groups        []ProxyGroupConfig `yaml:"groups"`         // Proxy group templates (default groups when empty)
+}
+
+// ProxyGroupConfig is a proxy group template of a subscription profile
+type ProxyGroupConfig struct {
+	Name        string   `yaml:"name"`         // Group name; supports {country}, {code}, {platform}, {region} and {flag}
+	Type        string   `yaml:"type"`         // select, url-test, fallback, load-balance
+	Members     string   `yaml:"members"`      // nodes (default), groups, all, none
+	PerCountry  bool     `yaml:"per_country"`  // One group per node country
+	PerPlatform bool     `yaml:"per_platform"` // One group per unlocked platform (and region)
+	Platforms   []string `yaml:"platforms"`    // Limit per-platform groups to these platforms
+	Proxies     []string `yaml:"proxies"`      // Static members, e.g. DIRECT
+	URL         string   `yaml:"url"`          // Health check URL
+	Interval    int      `yaml:"interval"`     // Health check interval in seconds
+	Tolerance   int      `yaml:"tolerance"`    // url-test tolerance in ms
+	Strategy    string   `yaml:"strategy"`     // load-balance strategy
+}
+
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -53,6 +124,23 @@ func DefaultConfig() *Config {
 			EnableConsole: true,
 			Format:        "text",
 		},
+		Storage: StorageConfig{
+			Enabled:       true,
+			Dir:           "data/tasks",
+			RetentionDays: 7,
+		},
+		Export: ExportConfig{
+			AllowServerWrite: false,
+			Dir:              "exports",
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:  true,
+			JobsFile: "data/jobs.json",
+		},
+		Geo: GeoConfig{
+			HTTPFallback: true,
+			CacheSize:    4096,
+		},
 	}
 }
 
@@ -139,6 +227,31 @@ func (c *Config) overrideWithEnv() {
 	if format := os.Getenv("LOG_FORMAT"); format != "" {
 		c.Logger.Format = strings.ToLower(format)
 	}
+
+	// Storage configuration
+	if enabled := os.Getenv("STORAGE_ENABLED"); enabled != "" {
+		c.Storage.Enabled = parseBool(enabled)
+	}
+	if dir := os.Getenv("STORAGE_DIR"); dir != "" {
+		c.Storage.Dir = dir
+	}
+
+	// Geo configuration
+	if path := os.Getenv("GEO_CITY_DB"); path != "" {
+		c.Geo.CityDB = path
+	}
+	if path := os.Getenv("GEO_COUNTRY_DB"); path != "" {
+		c.Geo.CountryDB = path
+	}
+	if path := os.Getenv("GEO_ASN_DB"); path != "" {
+		c.Geo.ASNDB = path
+	}
+	if path := os.Getenv("GEO_ASN_TABLE"); path != "" {
+		c.Geo.ASNTable = path
+	}
+	if fallback := os.Getenv("GEO_HTTP_FALLBACK"); fallback != "" {
+		c.Geo.HTTPFallback = parseBool(fallback)
+	}
 }
 
 // GetSlogLevel converts string log level to slog.Level
@@ -1,15 +1,26 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/netip"
+	"os"
 	"strconv"
+	"strings"
 
 	"github.com/zhsama/clash-speedtest/speedtester"
+	"github.com/zhsama/clash-speedtest/utils/stun"
 )
 
 func main() {
+	udpEcho := flag.Bool("udp-echo", false, "Start a UDP echo service on :8080/udp for UDP relay tests (udpServer)")
+	stunIPs := flag.String("stun-ips", "", "Start a STUN responder on two local IPs (\"primary,alternate\") for NAT type detection (stunServer)")
+	flag.Parse()
+
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html")
 		w.WriteHeader(http.StatusOK)
@@ -29,11 +40,23 @@ func main() {
 			return
 		}
 
+		// 带 seed 参数时返回由种子生成的伪随机数据，客户端可据此校验完整性
+		var reader io.Reader = speedtester.NewZeroReader(byteSize)
+		if seed := r.URL.Query().Get("seed"); seed != "" {
+			value, err := strconv.ParseUint(seed, 10, 64)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			reader = speedtester.NewPayloadReader(value, byteSize)
+		}
+
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=speedtest-%d.bin", byteSize))
 		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(byteSize))
 		w.WriteHeader(http.StatusOK)
 
-		reader := speedtester.NewZeroReader(byteSize)
 		io.Copy(w, reader)
 	})
 
@@ -43,10 +66,79 @@ func main() {
 			return
 		}
 
-		io.Copy(io.Discard, r.Body)
+		// 回显收到内容的校验和与字节数，客户端据此发现损坏或截断的上传
+		hash := speedtester.NewPayloadHash()
+		received, _ := io.Copy(hash, r.Body)
 
+		w.Header().Set(speedtester.PayloadChecksumHeader, speedtester.PayloadChecksum(hash))
+		w.Header().Set(speedtester.PayloadBytesHeader, strconv.FormatInt(received, 10))
 		w.WriteHeader(http.StatusOK)
 	})
 
+	// 以纯文本返回客户端 IP，可作为出口探测（egressEndpoints）的本地回显服务
+	http.HandleFunc("/__ip", func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(host + "\n"))
+	})
+
+	// UDP 回显服务，与 HTTP 共用端口，可作为 UDP 测试（udpServer）的目标
+	if *udpEcho {
+		go serveUDPEcho(":8080")
+	}
+
+	// STUN 应答服务，需要两个本机 IP，可作为 NAT 类型检测（stunServer）的目标
+	if *stunIPs != "" {
+		go serveSTUN(*stunIPs)
+	}
+
 	http.ListenAndServe(":8080", nil)
 }
+
+// serveUDPEcho 将收到的每个数据包原样发回
+func serveUDPEcho(addr string) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start UDP echo on %s: %v\n", addr, err)
+		return
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 65535)
+	for {
+		n, remote, err := conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			continue
+		}
+		conn.WriteTo(buf[:n], remote)
+	}
+}
+
+// serveSTUN 在两个 IP 的 3478/3479 端口上运行 STUN 应答服务
+func serveSTUN(ips string) {
+	primary, alternate, ok := strings.Cut(ips, ",")
+	primaryIP, err1 := netip.ParseAddr(strings.TrimSpace(primary))
+	alternateIP, err2 := netip.ParseAddr(strings.TrimSpace(alternate))
+	if !ok || err1 != nil || err2 != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -stun-ips %q: expected two IP addresses separated by a comma\n", ips)
+		return
+	}
+
+	server, err := stun.NewServer(primaryIP, alternateIP, stun.DefaultPort, stun.DefaultAlternatePort)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start STUN server: %v\n", err)
+		return
+	}
+	defer server.Close()
+
+	fmt.Printf("STUN server listening on %s\n", server.PrimaryAddr())
+	server.Serve()
+}
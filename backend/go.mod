@@ -6,6 +6,7 @@ require (
 	github.com/andybalholm/brotli v1.0.6
 	github.com/gobwas/ws v1.4.0
 	github.com/metacubex/mihomo v1.19.10
+	github.com/oschwald/maxminddb-golang v1.12.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
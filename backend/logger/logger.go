@@ -20,11 +20,12 @@ type LogConfig struct {
 	OutputToFile  bool
 	LogDir        string
 	LogFileName   string
-	MaxSize       int64 // Maximum log file size in bytes (default: 10MB)
-	MaxFiles      int   // Maximum number of log files to keep (default: 5)
-	RotateOnStart bool  // Whether to rotate log on startup
-	EnableConsole bool  // Whether to also output to console
-	Format        string // Log format: "text" or "json"
+	MaxSize       int64     // Maximum log file size in bytes (default: 10MB)
+	MaxFiles      int       // Maximum number of log files to keep (default: 5)
+	RotateOnStart bool      // Whether to rotate log on startup
+	EnableConsole bool      // Whether to also output to console
+	Format        string    // Log format: "text" or "json"
+	ConsoleWriter io.Writer // Console output destination (default: os.Stdout)
 }
 
 // DefaultLogConfig returns the default logging configuration
@@ -61,7 +62,10 @@ var currentConfig *LogConfig
 var logFile *os.File
 
 func init() {
-	InitLogger()
+	// Bootstrap logger until InitLoggerWithConfig is called; it writes to stderr
+	// only so that no log file is created before the configuration is loaded
+	Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	slog.SetDefault(Logger)
 }
 
 // InitLogger initializes the global logger with default configuration
@@ -86,9 +90,14 @@ func InitLoggerWithConfig(config *LogConfig) {
 
 	var writers []io.Writer
 
+	console := config.ConsoleWriter
+	if console == nil {
+		console = os.Stdout
+	}
+
 	// Add console output if enabled
 	if config.EnableConsole {
-		writers = append(writers, os.Stdout)
+		writers = append(writers, console)
 	}
 
 	// Add file output if enabled
@@ -97,7 +106,7 @@ func InitLoggerWithConfig(config *LogConfig) {
 		if err != nil {
 			// Fallback to console only if file setup fails
 			fmt.Fprintf(os.Stderr, "Failed to setup file logging: %v\n", err)
-			writers = []io.Writer{os.Stdout}
+			writers = []io.Writer{console}
 		} else {
 			writers = append(writers, fileWriter)
 		}
@@ -110,7 +119,7 @@ func InitLoggerWithConfig(config *LogConfig) {
 	} else if len(writers) > 1 {
 		writer = io.MultiWriter(writers...)
 	} else {
-		writer = os.Stdout // Fallback
+		writer = console // Fallback
 	}
 
 	// Create handler based on the format configuration
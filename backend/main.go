@@ -11,10 +11,16 @@ import (
 	"github.com/zhsama/clash-speedtest/logger"
 	"github.com/zhsama/clash-speedtest/server"
 	"github.com/zhsama/clash-speedtest/unlock"
+	"github.com/zhsama/clash-speedtest/utils/geo"
 	"github.com/metacubex/mihomo/log"
 )
 
 func main() {
+	// Headless subcommand: clash-speedtest run [flags]
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		os.Exit(runCommand(os.Args[2:]))
+	}
+
 	// Parse command line flags
 	var configPath string
 	flag.StringVar(&configPath, "config", "config.yaml", "Path to configuration file")
@@ -40,6 +46,11 @@ func main() {
 	// Register unlock detectors
 	registerUnlockDetectors()
 
+	// Configure geolocation providers; on failure the HTTP service and the bundled ASN table are used
+	if err := geo.Configure(geoOptions(appConfig.Geo)); err != nil {
+		logger.LogError("Failed to configure geo lookups, falling back to defaults", err)
+	}
+
 	logger.Logger.Info("Starting Clash SpeedTest API Server",
 		slog.String("version", "2.0.0"),
 		slog.String("port", fmt.Sprintf("%d", appConfig.Server.Port)),
@@ -47,7 +58,7 @@ func main() {
 	)
 
 	// Create and start server
-	srv := server.NewServer(appConfig.Server.Port)
+	srv := server.NewServer(appConfig)
 	
 	// Start the server (this will block until shutdown)
 	if err := srv.Start(); err != nil {
@@ -58,6 +69,18 @@ func main() {
 	logger.Logger.Info("Server exited")
 }
 
+// geoOptions 将配置文件中的 geo 配置转换为地理位置查询选项
+func geoOptions(geoConfig config.GeoConfig) geo.Options {
+	return geo.Options{
+		CityDB:       geoConfig.CityDB,
+		CountryDB:    geoConfig.CountryDB,
+		ASNDB:        geoConfig.ASNDB,
+		ASNTable:     geoConfig.ASNTable,
+		HTTPFallback: geoConfig.HTTPFallback,
+		CacheSize:    geoConfig.CacheSize,
+	}
+}
+
 // registerUnlockDetectors 注册所有解锁检测器
 func registerUnlockDetectors() {
 	logger.Logger.Info("Registering unlock detectors")
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/metacubex/mihomo/log"
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/zhsama/clash-speedtest/server/common"
+	"github.com/zhsama/clash-speedtest/speedtester"
+	"github.com/zhsama/clash-speedtest/tasks"
+	"github.com/zhsama/clash-speedtest/utils/export"
+	"github.com/zhsama/clash-speedtest/utils/geo"
+)
+
+// runOptions holds output options of the run subcommand
+type runOptions struct {
+	format          string
+	output          string
+	sortBy          string
+	topN            int
+	includeFailures bool
+	asnTypes        []string
+	verbose         bool
+}
+
+// runCommand runs a headless speed test and prints the results, returning the exit code
+func runCommand(args []string) int {
+	var req common.TestRequest
+	var opts runOptions
+	var includeNodes, excludeNodes, protocols, unlockPlatforms string
+	var egressEndpoints, egressEndpointsV6 string
+	var latencyProbes string
+	var bandwidthServers string
+	var geoOpts geo.Options
+	var geoOffline bool
+	var asnTypes string
+
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: clash-speedtest run -c <config paths> [flags]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	// Test options, mirroring common.TestRequest
+	fs.StringVar(&req.ConfigPaths, "c", "", "Config file paths or subscription URLs, comma separated")
+	fs.StringVar(&req.FilterRegex, "f", ".+", "Filter proxies by name regex")
+	fs.StringVar(&includeNodes, "include", "", "Only test proxies whose names contain these keywords, comma separated")
+	fs.StringVar(&excludeNodes, "exclude", "", "Skip proxies whose names contain these keywords, comma separated")
+	fs.StringVar(&protocols, "protocols", "", "Only test these protocols, comma separated (e.g. vmess,trojan)")
+	fs.StringVar(&req.ServerURL, "server-url", "https://speed.cloudflare.com", "Speed test server URL (the file URL for -backend file)")
+	fs.StringVar(&req.Backend, "backend", "cloudflare", "Bandwidth backend: cloudflare, download-server, librespeed or file")
+	fs.StringVar(&bandwidthServers, "servers", "", "Several bandwidth servers for -backend, comma separated (overrides -server-url for bandwidth)")
+	fs.StringVar(&req.ServerSelection, "server-selection", "nearest", "With -servers: nearest (lowest latency from each node) or all (test every server, keep the fastest)")
+	fs.IntVar(&req.DownloadSize, "download-size", 50, "Download size in MB")
+	fs.IntVar(&req.UploadSize, "upload-size", 20, "Upload size in MB")
+	fs.IntVar(&req.TestDuration, "duration", 0, "Stream each direction for this many seconds instead of a fixed size (0 = fixed size)")
+	fs.IntVar(&req.SampleInterval, "sample-interval", 200, "Throughput sample interval in ms for -duration (100-250)")
+	fs.IntVar(&req.SaturationStreams, "saturation-streams", 0, "Ramp download streams 1, 2, 4, ... up to N to find per-connection throttling (0 = off)")
+	fs.IntVar(&req.SaturationStage, "saturation-stage", 3, "Seconds per saturation stage")
+	fs.BoolVar(&req.Bufferbloat, "bufferbloat", false, "Measure latency while downloading and uploading and grade the bufferbloat")
+	fs.BoolVar(&req.VerifyPayload, "verify-payload", false, "Send seeded random data and fail nodes that corrupt or truncate it (downloads need -backend download-server)")
+	fs.IntVar(&req.Timeout, "timeout", 5, "Timeout per test in seconds")
+	fs.IntVar(&req.Concurrent, "concurrent", 4, "Connections per bandwidth test")
+	fs.IntVar(&req.NodeConcurrent, "node-concurrent", 4, "Proxies tested in parallel")
+	fs.IntVar(&req.MaxSpeedTests, "max-speed-tests", 1, "Bandwidth tests running at once")
+	fs.BoolVar(&req.Pipeline, "pipeline", false, "Screen latency first, then bandwidth-test the survivors")
+	fs.IntVar(&req.PipelineTopN, "pipeline-top-n", 0, "Only bandwidth-test the N fastest proxies in pipeline mode (0 = all)")
+	fs.IntVar(&req.MaxLatency, "max-latency", 800, "Maximum latency in ms")
+	fs.IntVar(&req.PingCount, "ping-count", 0, "Latency requests per proxy (0 = 6, or 3 for VLESS)")
+	fs.IntVar(&req.PingInterval, "ping-interval", 100, "Interval between latency requests in ms")
+	fs.StringVar(&req.LatencyURL, "latency-url", "", "Latency probe URL (default: chosen by -backend, <server-url>/__down?bytes=0 for cloudflare)")
+	fs.StringVar(&latencyProbes, "latency-probes", "", "Extra latency probes reported side by side, comma separated: http (mihomo url-test), tcp, tls")
+	fs.StringVar(&req.ProbeURL, "probe-url", "", "Target of -latency-probes; tcp and tls connect to its host (default \""+speedtester.DefaultProbeURL+"\")")
+	fs.StringVar(&req.ProbeStatus, "probe-status", "", "Expected status of the http probe, e.g. 204 or 200-299 (default: any)")
+	fs.Float64Var(&req.MinDownloadSpeed, "min-download", 0, "Minimum download speed in MB/s")
+	fs.Float64Var(&req.MinUploadSpeed, "min-upload", 0, "Minimum upload speed in MB/s")
+	fs.BoolVar(&req.StashCompatible, "stash-compatible", false, "Only test Stash-compatible proxies")
+	fs.BoolVar(&req.FastMode, "fast", false, "Only test latency")
+	fs.BoolVar(&req.RenameNodes, "rename", false, "Rename proxies after the location of their exit IP")
+	fs.StringVar(&req.RenameTemplate, "rename-template", "", "Name template for -rename (default \""+speedtester.DefaultRenameTemplate+"\")")
+	fs.BoolVar(&req.EgressProbe, "egress", false, "Probe exit IPv4/IPv6 through each proxy and flag relays")
+	fs.StringVar(&egressEndpoints, "egress-endpoints", "", "IPv4 echo URLs returning the caller IP as plain text, comma separated")
+	fs.StringVar(&egressEndpointsV6, "egress-endpoints-v6", "", "IPv6 echo URLs, comma separated")
+	fs.BoolVar(&req.DualStack, "dual-stack", false, "Probe IPv4-only and IPv6-only targets through each proxy")
+	fs.BoolVar(&req.RequireIPv6, "require-ipv6", false, "Fail proxies that cannot reach the IPv6 target (enables -dual-stack)")
+	fs.StringVar(&req.IPv4Target, "ipv4-target", "", "IPv4-only probe URL with an IPv4 address host (default \""+speedtester.DefaultIPv4Target+"\")")
+	fs.StringVar(&req.IPv6Target, "ipv6-target", "", "IPv6-only probe URL with an IPv6 address host (default \""+speedtester.DefaultIPv6Target+"\")")
+	fs.StringVar(&req.UDPServer, "udp-server", "", "UDP echo server (host:port, e.g. the download-server) for the UDP relay test")
+	fs.IntVar(&req.UDPPackets, "udp-packets", speedtester.DefaultUDPPackets, "Datagrams sent per proxy in the UDP test")
+	fs.StringVar(&req.STUNServer, "stun-server", "", "RFC 5780 STUN server (host:port, e.g. the download-server responder) for NAT type detection")
+	fs.StringVar(&geoOpts.CityDB, "geo-city-db", "", "GeoLite2-City compatible .mmdb file for offline IP geolocation")
+	fs.StringVar(&geoOpts.CountryDB, "geo-country-db", "", "GeoLite2-Country compatible .mmdb file, used when -geo-city-db is unset")
+	fs.StringVar(&geoOpts.ASNDB, "geo-asn-db", "", "GeoLite2-ASN compatible .mmdb file")
+	fs.StringVar(&geoOpts.ASNTable, "geo-asn-table", "", "ASN category table merged over the bundled one (\"<asn> <category>\" per line)")
+	fs.BoolVar(&geoOffline, "geo-offline", false, "Never query ip-api.com, only the .mmdb files")
+	fs.StringVar(&req.TestMode, "mode", "speed_only", "Test mode: speed_only, unlock_only, both")
+	fs.StringVar(&unlockPlatforms, "unlock-platforms", "", "Unlock platforms to check, comma separated")
+	fs.IntVar(&req.UnlockConcurrent, "unlock-concurrent", 5, "Unlock checks running at once")
+	fs.IntVar(&req.UnlockTimeout, "unlock-timeout", 10, "Unlock check timeout in seconds")
+	fs.BoolVar(&req.UnlockRetry, "unlock-retry", false, "Retry failed unlock checks")
+
+	// Output options
+	fs.StringVar(&opts.format, "format", "table", "Output format: table, json, csv, yaml, clash")
+	fs.StringVar(&opts.output, "o", "", "Write output to this file instead of stdout")
+	fs.StringVar(&opts.sortBy, "sort", "latency", "Sort results by: latency, download, upload, name")
+	fs.IntVar(&opts.topN, "top", 0, "Only output the top N results (0 = all)")
+	fs.BoolVar(&opts.includeFailures, "include-failures", false, "Include failed proxies in the output")
+	fs.StringVar(&asnTypes, "asn-types", "", "Only output proxies whose exit ASN is one of these categories, comma separated (residential,datacenter,mobile,education; enables -egress)")
+	fs.BoolVar(&opts.verbose, "v", false, "Print logs to stderr")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	req.IncludeNodes = splitList(includeNodes)
+	req.ExcludeNodes = splitList(excludeNodes)
+	req.ProtocolFilter = splitList(protocols)
+	req.UnlockPlatforms = splitList(unlockPlatforms)
+	req.EgressEndpoints = splitList(egressEndpoints)
+	req.EgressEndpointsV6 = splitList(egressEndpointsV6)
+	req.LatencyProbes = splitList(latencyProbes)
+	req.BandwidthServers = splitList(bandwidthServers)
+	opts.asnTypes = splitList(asnTypes)
+	if len(opts.asnTypes) > 0 {
+		req.EgressProbe = true
+	}
+
+	common.SetRequestDefaults(&req)
+	if err := common.ValidateRequest(&req); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid arguments: %v\n", err)
+		return 2
+	}
+
+	if opts.format != "table" {
+		if err := export.ValidateExportOptions(export.ExportOptions{Format: export.ExportFormat(opts.format), TopN: opts.topN, ASNTypes: opts.asnTypes}); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid arguments: %v\n", err)
+			return 2
+		}
+	}
+	for _, asnType := range opts.asnTypes {
+		if !geo.IsASNCategory(strings.ToLower(asnType)) {
+			fmt.Fprintf(os.Stderr, "Invalid arguments: unsupported asn type %q, supported: %s\n", asnType, strings.Join(geo.ASNCategories, ", "))
+			return 2
+		}
+	}
+
+	// 日志默认丢弃，-v 时输出到 stderr，避免污染 stdout 上的结果
+	logConfig := logger.DefaultLogConfig()
+	logConfig.OutputToFile = false
+	logConfig.ConsoleWriter = io.Discard
+	if opts.verbose {
+		logConfig.ConsoleWriter = os.Stderr
+	}
+	logger.InitLoggerWithConfig(logConfig)
+	defer logger.Cleanup()
+
+	log.SetLevel(log.SILENT)
+	registerUnlockDetectors()
+
+	geoOpts.HTTPFallback = !geoOffline
+	if err := geo.Configure(geoOpts); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to configure geo lookups: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	results, err := runSpeedTest(ctx, &req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Speed test failed: %v\n", err)
+		return 1
+	}
+
+	if err := writeRunOutput(results, &req, &opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write output: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// runSpeedTest loads proxies and tests them, returning partial results when cancelled
+func runSpeedTest(ctx context.Context, req *common.TestRequest) ([]*speedtester.Result, error) {
+	speedTester := tasks.NewSpeedTester(req)
+
+	allProxies, err := speedTester.LoadProxies(req.StashCompatible)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load proxies: %w", err)
+	}
+	if len(allProxies) == 0 {
+		return nil, fmt.Errorf("no proxies found")
+	}
+
+	fmt.Fprintf(os.Stderr, "Testing %d proxies...\n", len(allProxies))
+
+	results := make([]*speedtester.Result, 0, len(allProxies))
+	err = speedTester.TestProxiesWithContext(ctx, allProxies, func(result *speedtester.Result) {
+		results = append(results, result)
+		logger.Logger.Info("Proxy tested",
+			slog.String("proxy_name", result.ProxyName),
+			slog.Int("completed", len(results)),
+			slog.Int("total", len(allProxies)))
+	})
+	if err != nil && err != context.Canceled {
+		return nil, err
+	}
+	if err == context.Canceled {
+		fmt.Fprintf(os.Stderr, "Interrupted, %d of %d proxies tested\n", len(results), len(allProxies))
+	}
+
+	return results, nil
+}
+
+// writeRunOutput writes the results in the requested format
+func writeRunOutput(results []*speedtester.Result, req *common.TestRequest, opts *runOptions) error {
+	if opts.format != "table" {
+		exporter := export.NewExporter()
+		testTime := time.Now()
+		for _, result := range results {
+			status := tasks.DetermineResultStatus(result, req)
+			exporter.AddResult(export.FromSpeedtestResult(result, status, testTime, nil))
+		}
+
+		exportOptions := export.ExportOptions{
+			Format:          export.ExportFormat(opts.format),
+			OutputPath:      opts.output,
+			IncludeFailures: opts.includeFailures,
+			SortBy:          opts.sortBy,
+			TopN:            opts.topN,
+			ASNTypes:        opts.asnTypes,
+		}
+		if opts.output != "" {
+			return exporter.Export(exportOptions)
+		}
+		return exporter.ExportTo(os.Stdout, exportOptions)
+	}
+
+	var w io.Writer = os.Stdout
+	if opts.output != "" {
+		file, err := os.Create(opts.output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	return writeResultTable(w, results, req, opts)
+}
+
+// matchesASNType reports whether the result's exit ASN category is one of types (empty matches all)
+func matchesASNType(result *speedtester.Result, types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, asnType := range types {
+		if strings.EqualFold(asnType, result.ASNType) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeResultTable prints results as an aligned table
+func writeResultTable(w io.Writer, results []*speedtester.Result, req *common.TestRequest, opts *runOptions) error {
+	rows := make([]*speedtester.Result, 0, len(results))
+	statuses := make(map[*speedtester.Result]string, len(results))
+	for _, result := range results {
+		status := tasks.DetermineResultStatus(result, req)
+		if status != "success" && !opts.includeFailures {
+			continue
+		}
+		if !matchesASNType(result, opts.asnTypes) {
+			continue
+		}
+		statuses[result] = status
+		rows = append(rows, result)
+	}
+
+	sortRunResults(rows, opts.sortBy)
+	if opts.topN > 0 && len(rows) > opts.topN {
+		rows = rows[:opts.topN]
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tNAME\tTYPE\tLATENCY\tJITTER\tLOSS\tDOWNLOAD\tUPLOAD\tSTATUS")
+	for i, result := range rows {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			i+1,
+			result.ProxyName,
+			result.ProxyType,
+			result.FormatLatency(),
+			result.FormatJitter(),
+			result.FormatPacketLoss(),
+			result.FormatDownloadSpeed(),
+			result.FormatUploadSpeed(),
+			statuses[result],
+		)
+	}
+	return tw.Flush()
+}
+
+// sortRunResults sorts results for table output
+func sortRunResults(results []*speedtester.Result, sortBy string) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		switch sortBy {
+		case "download":
+			return a.DownloadSpeed > b.DownloadSpeed
+		case "upload":
+			return a.UploadSpeed > b.UploadSpeed
+		case "name":
+			return a.ProxyName < b.ProxyName
+		default:
+			// Results without latency go last
+			if (a.Latency == 0) != (b.Latency == 0) {
+				return b.Latency == 0
+			}
+			return a.Latency < b.Latency
+		}
+	})
+}
+
+// splitList splits a comma separated flag value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
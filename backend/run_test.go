@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhsama/clash-speedtest/utils/export"
+)
+
+// connectProxy 是一个只支持 CONNECT 的 HTTP 代理，用作本地测试节点
+func connectProxy(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		dst, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			dst.Close()
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() { io.Copy(dst, buf); dst.Close() }()
+		io.Copy(conn, dst)
+		conn.Close()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// runMainEnv 被设置时测试二进制以自身参数直接运行 main
+const runMainEnv = "CLASH_SPEEDTEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestRunCommandJSONOutput(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	proxyURL, _ := url.Parse(connectProxy(t).URL)
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	config := "proxies:\n" +
+		"  - name: local\n" +
+		"    type: http\n" +
+		"    server: " + proxyURL.Hostname() + "\n" +
+		"    port: " + proxyURL.Port() + "\n"
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 在子进程中运行，包初始化阶段的输出也会被捕获
+	args := []string{
+		"run",
+		"-c", configPath,
+		"-fast",
+		"-latency-url", target.URL,
+		"-ping-count", "2",
+		"-format", "json",
+		"-include-failures",
+	}
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), runMainEnv+"=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("run command failed: %v\n%s", err, stderr.Bytes())
+	}
+
+	var data struct {
+		Metadata struct {
+			TotalResults int `json:"total_results"`
+		} `json:"metadata"`
+		Results []export.ExportableResult `json:"results"`
+	}
+	if err := json.Unmarshal(stdout, &data); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, stdout)
+	}
+	if data.Metadata.TotalResults != 1 || len(data.Results) != 1 {
+		t.Fatalf("got %d results (total %d), want 1", len(data.Results), data.Metadata.TotalResults)
+	}
+	if result := data.Results[0]; result.ProxyName != "local" || result.Status != "success" {
+		t.Errorf("result = %q with status %q, want \"local\" with status \"success\"", result.ProxyName, result.Status)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "logs")); !os.IsNotExist(err) {
+		t.Errorf("run command created a logs directory")
+	}
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"empty", ""},
+		{"too few fields", "0 0 * *"},
+		{"too many fields", "0 0 * * * *"},
+		{"minute out of range", "60 * * * *"},
+		{"hour out of range", "0 24 * * *"},
+		{"day of month zero", "0 0 0 * *"},
+		{"month out of range", "0 0 1 13 *"},
+		{"day of week out of range", "0 0 * * 8"},
+		{"reversed range", "0 10-5 * * *"},
+		{"zero step", "*/0 * * * *"},
+		{"invalid step", "*/x * * * *"},
+		{"unknown name", "0 0 * foo *"},
+		{"every too short", "@every 30s"},
+		{"every invalid duration", "@every soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSchedule(tt.spec); err == nil {
+				t.Errorf("ParseSchedule(%q) succeeded, want error", tt.spec)
+			}
+		})
+	}
+}
+
+func TestParseField(t *testing.T) {
+	tests := []struct {
+		expr  string
+		field cronField
+		want  []int
+	}{
+		{"5", minuteField, []int{5}},
+		{"1,3,5", minuteField, []int{1, 3, 5}},
+		{"10-13", minuteField, []int{10, 11, 12, 13}},
+		{"*/15", minuteField, []int{0, 15, 30, 45}},
+		{"5/20", minuteField, []int{5, 25, 45}},
+		{"0-10/5", minuteField, []int{0, 5, 10}},
+		{"*/6", hourField, []int{0, 6, 12, 18}},
+		{"JAN,mar", monthField, []int{1, 3}},
+		{"mon-fri", dowField, []int{1, 2, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := parseField(tt.expr, tt.field)
+			if err != nil {
+				t.Fatalf("parseField(%q) error: %v", tt.expr, err)
+			}
+			var want uint64
+			for _, v := range tt.want {
+				want |= 1 << uint(v)
+			}
+			if got != want {
+				t.Errorf("parseField(%q) = %b, want %b", tt.expr, got, want)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// 2025-01-15 是星期三
+	base := time.Date(2025, 1, 15, 10, 30, 20, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", base, time.Date(2025, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"strictly after", "30 10 * * *", time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC), time.Date(2025, 1, 16, 10, 30, 0, 0, time.UTC)},
+		{"step minutes", "*/15 * * * *", base, time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"next hour", "5 * * * *", base, time.Date(2025, 1, 15, 11, 5, 0, 0, time.UTC)},
+		{"daily", "@daily", base, time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"hourly", "@hourly", base, time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"weekly on sunday", "@weekly", base, time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"sunday as 7", "0 0 * * 7", base, time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"monthly", "@monthly", base, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"yearly", "@yearly", base, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"month name", "0 8 1 mar *", base, time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)},
+		{"weekdays", "0 9 * * mon-fri", time.Date(2025, 1, 17, 12, 0, 0, 0, time.UTC), time.Date(2025, 1, 20, 9, 0, 0, 0, time.UTC)},
+		// 日与周同时受限时满足其一即可：1 月 17 日是星期五，早于 20 日
+		{"day of month or week", "0 0 20 * fri", base, time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", base, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"never", "0 0 30 2 *", base, time.Time{}},
+		{"every interval", "@every 1h30m", base, time.Date(2025, 1, 15, 12, 0, 20, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q) error: %v", tt.spec, err)
+			}
+			if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) for %q = %s, want %s", tt.from, tt.spec, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,541 @@
+package scheduler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/zhsama/clash-speedtest/server/common"
+	"github.com/zhsama/clash-speedtest/tasks"
+)
+
+// RunStatusFailed 提交任务失败时的运行状态，其余状态与任务状态一致
+const RunStatusFailed = "failed"
+
+// Job 定时测试任务
+type Job struct {
+	ID          string              `json:"id"`
+	Name        string              `json:"name"`
+	Cron        string              `json:"cron"`
+	Enabled     bool                `json:"enabled"`
+	Request     *common.TestRequest `json:"request"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	NextRun     *time.Time          `json:"next_run,omitempty"`
+	LastRun     *JobRun             `json:"last_run,omitempty"`
+	LastSuccess *JobRun             `json:"last_success,omitempty"` // 最近一次成功完成的运行
+	LastSkipped *time.Time          `json:"last_skipped,omitempty"` // 因上一次运行未结束而跳过的时间
+	RunCount    int                 `json:"run_count"`
+	SkipCount   int                 `json:"skip_count"`
+}
+
+// JobRun 最近一次触发的记录
+type JobRun struct {
+	TaskID    string    `json:"task_id,omitempty"`
+	Status    string    `json:"status"`
+	StartTime time.Time `json:"start_time"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// JobSpec 创建或更新定时任务的参数
+type JobSpec struct {
+	Name    string             `json:"name"`
+	Cron    string             `json:"cron"`
+	Enabled *bool              `json:"enabled"` // 默认启用
+	Request common.TestRequest `json:"request"`
+}
+
+// jobEntry 任务及其解析后的调度
+type jobEntry struct {
+	job      *Job
+	schedule Schedule
+}
+
+// Scheduler 定时任务调度器
+type Scheduler struct {
+	taskManager *tasks.Manager
+	path        string // 任务持久化文件，为空时仅保存在内存中
+
+	jobs  map[string]*jobEntry
+	mutex sync.Mutex
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New 创建调度器并加载已保存的任务
+func New(taskManager *tasks.Manager, path string) *Scheduler {
+	s := &Scheduler{
+		taskManager: taskManager,
+		path:        path,
+		jobs:        make(map[string]*jobEntry),
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+	}
+
+	s.load()
+	taskManager.SetRetainedFunc(s.retainedTasks)
+	return s
+}
+
+// retainedTasks 返回各定时任务最近一次成功的运行，订阅依赖其结果，不能被过期清理删除
+func (s *Scheduler) retainedTasks() map[string]bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	retained := make(map[string]bool)
+	for _, entry := range s.jobs {
+		s.refreshLastRun(entry.job)
+		if entry.job.LastSuccess != nil && entry.job.LastSuccess.TaskID != "" {
+			retained[entry.job.LastSuccess.TaskID] = true
+		}
+	}
+	return retained
+}
+
+// Start 启动调度循环
+func (s *Scheduler) Start() {
+	go s.loop()
+	logger.Logger.Info("Scheduler started", slog.Int("jobs", len(s.jobs)))
+}
+
+// Stop 停止调度循环，已提交的任务不受影响
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	logger.Logger.Info("Scheduler stopped")
+}
+
+// CreateJob 创建定时任务
+func (s *Scheduler) CreateJob(spec *JobSpec) (*Job, error) {
+	schedule, err := validateSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:        generateJobID(),
+		CreatedAt: now,
+	}
+	applySpec(job, spec, now)
+
+	s.mutex.Lock()
+	s.jobs[job.ID] = &jobEntry{job: job, schedule: schedule}
+	s.updateNextRun(s.jobs[job.ID], now)
+	snapshot := s.snapshot(s.jobs[job.ID])
+	s.saveLocked()
+	s.mutex.Unlock()
+
+	s.notify()
+
+	logger.Logger.Info("Scheduled job created",
+		slog.String("job_id", job.ID),
+		slog.String("name", job.Name),
+		slog.String("cron", job.Cron))
+
+	return snapshot, nil
+}
+
+// UpdateJob 更新定时任务，运行记录保留
+func (s *Scheduler) UpdateJob(id string, spec *JobSpec) (*Job, error) {
+	schedule, err := validateSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	entry, exists := s.jobs[id]
+	if !exists {
+		s.mutex.Unlock()
+		return nil, fmt.Errorf("job not found: %s", id)
+	}
+
+	now := time.Now()
+	applySpec(entry.job, spec, now)
+	entry.schedule = schedule
+	s.updateNextRun(entry, now)
+	snapshot := s.snapshot(entry)
+	s.saveLocked()
+	s.mutex.Unlock()
+
+	s.notify()
+
+	logger.Logger.Info("Scheduled job updated", slog.String("job_id", id))
+	return snapshot, nil
+}
+
+// DeleteJob 删除定时任务
+func (s *Scheduler) DeleteJob(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.jobs[id]; !exists {
+		return fmt.Errorf("job not found: %s", id)
+	}
+
+	delete(s.jobs, id)
+	s.saveLocked()
+
+	logger.Logger.Info("Scheduled job deleted", slog.String("job_id", id))
+	return nil
+}
+
+// GetJob 获取定时任务
+func (s *Scheduler) GetJob(id string) (*Job, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, exists := s.jobs[id]
+	if !exists {
+		return nil, false
+	}
+	return s.snapshot(entry), true
+}
+
+// ListJobs 按创建时间列出所有定时任务
+func (s *Scheduler) ListJobs() []*Job {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, entry := range s.jobs {
+		jobs = append(jobs, s.snapshot(entry))
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// FindJob 按 ID 或名称查找定时任务
+func (s *Scheduler) FindJob(ref string) (*Job, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if entry, exists := s.jobs[ref]; exists {
+		return s.snapshot(entry), true
+	}
+	for _, entry := range s.jobs {
+		if entry.job.Name == ref {
+			return s.snapshot(entry), true
+		}
+	}
+	return nil, false
+}
+
+// RunJob 立即触发一次定时任务，不影响下一次计划时间
+func (s *Scheduler) RunJob(id string) (*Job, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, exists := s.jobs[id]
+	if !exists {
+		return nil, fmt.Errorf("job not found: %s", id)
+	}
+
+	s.fire(entry, time.Now())
+	s.saveLocked()
+	return s.snapshot(entry), nil
+}
+
+// loop 等待最近一次触发时间并执行到期任务
+func (s *Scheduler) loop() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		timer.Reset(s.untilNextRun())
+
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-timer.C:
+			s.runDueJobs(time.Now())
+		}
+	}
+}
+
+// untilNextRun 返回距离最近一次触发的时间
+func (s *Scheduler) untilNextRun() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	wait := time.Hour
+	for _, entry := range s.jobs {
+		if entry.job.NextRun == nil {
+			continue
+		}
+		if d := time.Until(*entry.job.NextRun); d < wait {
+			wait = d
+		}
+	}
+
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// runDueJobs 触发所有到期的任务
+func (s *Scheduler) runDueJobs(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fired := 0
+	for _, entry := range s.jobs {
+		if entry.job.NextRun == nil || entry.job.NextRun.After(now) {
+			continue
+		}
+		s.fire(entry, now)
+		s.updateNextRun(entry, now)
+		fired++
+	}
+
+	if fired > 0 {
+		s.saveLocked()
+	}
+}
+
+// fire 提交测试任务，上一次运行未结束时跳过（调用方需持有锁）
+func (s *Scheduler) fire(entry *jobEntry, now time.Time) {
+	job := entry.job
+	s.refreshLastRun(job)
+
+	if job.LastRun != nil && job.LastRun.TaskID != "" {
+		if task, exists := s.taskManager.GetTask(job.LastRun.TaskID); exists && !isDone(task) {
+			job.SkipCount++
+			job.LastSkipped = &now
+			logger.Logger.Warn("Skipping scheduled run, previous run still in progress",
+				slog.String("job_id", job.ID),
+				slog.String("name", job.Name),
+				slog.String("task_id", job.LastRun.TaskID))
+			return
+		}
+	}
+
+	// 每次运行使用请求副本，避免任务执行期间被更新修改
+	req := *job.Request
+	task, err := s.taskManager.SubmitTask(&req, nil)
+	job.RunCount++
+	if err != nil {
+		job.LastRun = &JobRun{Status: RunStatusFailed, StartTime: now, Error: err.Error()}
+		logger.LogError("Failed to submit scheduled run", err,
+			slog.String("job_id", job.ID),
+			slog.String("name", job.Name))
+		return
+	}
+
+	job.LastRun = &JobRun{
+		TaskID:    task.ID(),
+		Status:    string(task.Status()),
+		StartTime: now,
+	}
+
+	logger.Logger.Info("Scheduled run submitted",
+		slog.String("job_id", job.ID),
+		slog.String("name", job.Name),
+		slog.String("task_id", task.ID()))
+}
+
+// refreshLastRun 从任务管理器同步最近一次运行的状态（调用方需持有锁）
+func (s *Scheduler) refreshLastRun(job *Job) {
+	if job.LastRun == nil || job.LastRun.TaskID == "" {
+		return
+	}
+
+	task, exists := s.taskManager.GetTask(job.LastRun.TaskID)
+	if !exists {
+		return
+	}
+
+	job.LastRun.Status = string(task.Status())
+	if err := task.Error(); err != nil {
+		job.LastRun.Error = err.Error()
+	}
+
+	if task.Status() == tasks.TaskStatusCompleted {
+		lastSuccess := *job.LastRun
+		job.LastSuccess = &lastSuccess
+	}
+}
+
+// updateNextRun 重新计算下一次触发时间（调用方需持有锁）
+func (s *Scheduler) updateNextRun(entry *jobEntry, now time.Time) {
+	entry.job.NextRun = nil
+	if !entry.job.Enabled {
+		return
+	}
+
+	if next := entry.schedule.Next(now); !next.IsZero() {
+		entry.job.NextRun = &next
+	}
+}
+
+// snapshot 返回任务副本（调用方需持有锁）
+func (s *Scheduler) snapshot(entry *jobEntry) *Job {
+	s.refreshLastRun(entry.job)
+
+	job := *entry.job
+	if job.LastRun != nil {
+		lastRun := *job.LastRun
+		job.LastRun = &lastRun
+	}
+	if job.LastSuccess != nil {
+		lastSuccess := *job.LastSuccess
+		job.LastSuccess = &lastSuccess
+	}
+	req := *job.Request
+	job.Request = &req
+	return &job
+}
+
+// notify 唤醒调度循环以重新计算等待时间
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// load 从文件加载任务
+func (s *Scheduler) load() {
+	if s.path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.LogError("Failed to read scheduled jobs", err, slog.String("path", s.path))
+		}
+		return
+	}
+
+	var jobs []*Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		logger.LogError("Failed to parse scheduled jobs", err, slog.String("path", s.path))
+		return
+	}
+
+	now := time.Now()
+	for _, job := range jobs {
+		schedule, err := ParseSchedule(job.Cron)
+		if err != nil || job.Request == nil {
+			logger.Logger.Warn("Skipping invalid scheduled job",
+				slog.String("job_id", job.ID),
+				slog.String("cron", job.Cron))
+			continue
+		}
+
+		entry := &jobEntry{job: job, schedule: schedule}
+		// 停机期间错过的触发不再补跑
+		s.updateNextRun(entry, now)
+		s.jobs[job.ID] = entry
+	}
+
+	logger.Logger.Info("Scheduled jobs loaded", slog.Int("count", len(s.jobs)))
+}
+
+// saveLocked 将任务写入文件（调用方需持有锁）
+func (s *Scheduler) saveLocked() {
+	if s.path == "" {
+		return
+	}
+
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, entry := range s.jobs {
+		jobs = append(jobs, entry.job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		logger.LogError("Failed to marshal scheduled jobs", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		logger.LogError("Failed to create scheduled jobs directory", err, slog.String("path", s.path))
+		return
+	}
+
+	// 先写临时文件再重命名，避免写入中断导致文件损坏
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		logger.LogError("Failed to write scheduled jobs", err, slog.String("path", tmpPath))
+		return
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		logger.LogError("Failed to save scheduled jobs", err, slog.String("path", s.path))
+	}
+}
+
+// validateSpec 校验任务参数并解析 cron 表达式
+func validateSpec(spec *JobSpec) (Schedule, error) {
+	if spec.Name == "" {
+		return nil, common.NewValidationError("name is required")
+	}
+
+	schedule, err := ParseSchedule(spec.Cron)
+	if err != nil {
+		return nil, common.NewValidationError("invalid cron expression: " + err.Error())
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return nil, common.NewValidationError("cron expression never fires: " + spec.Cron)
+	}
+
+	common.SetRequestDefaults(&spec.Request)
+	if err := common.ValidateRequest(&spec.Request); err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// applySpec 将参数写入任务
+func applySpec(job *Job, spec *JobSpec, now time.Time) {
+	job.Name = spec.Name
+	job.Cron = spec.Cron
+	job.Enabled = spec.Enabled == nil || *spec.Enabled
+	req := spec.Request
+	job.Request = &req
+	job.UpdatedAt = now
+}
+
+// isDone 判断任务是否已结束；取消的任务在执行器退出后才算结束，状态变为已取消时仍可能在运行
+func isDone(task tasks.Task) bool {
+	select {
+	case <-task.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// generateJobID 生成任务 ID
+func generateJobID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return "job-" + hex.EncodeToString(bytes)
+}
@@ -1,5 +1,14 @@
 package common
 
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+
+	"github.com/metacubex/mihomo/common/utils"
+)
+
 // TestRequest 表示测试请求的结构
 type TestRequest struct {
 	ConfigPaths      string   `json:"configPaths"`
@@ -12,15 +21,20 @@ type TestRequest struct {
 	UploadSize       int      `json:"uploadSize"`
 	Timeout          int      `json:"timeout"`
 	Concurrent       int      `json:"concurrent"`
+	NodeConcurrent   int      `json:"nodeConcurrent"` // 同时测试的节点数
+	MaxSpeedTests    int      `json:"maxSpeedTests"`  // 同时进行带宽测试的节点数上限
+	Pipeline         bool     `json:"pipeline"`       // 两阶段模式：先筛选延迟，再测速
+	PipelineTopN     int      `json:"pipelineTopN"`   // 两阶段模式下进入测速的节点数上限
 	MaxLatency       int      `json:"maxLatency"`
 	MinDownloadSpeed float64  `json:"minDownloadSpeed"`
 	MinUploadSpeed   float64  `json:"minUploadSpeed"`
 	StashCompatible  bool     `json:"stashCompatible"`
 	// 新增字段
-	FastMode     bool   `json:"fastMode"`     // 快速模式：只测试延迟
-	RenameNodes  bool   `json:"renameNodes"`  // 节点重命名：添加地理位置信息
-	ExportFormat string `json:"exportFormat"` // 导出格式：json, csv, yaml, clash
-	ExportPath   string `json:"exportPath"`   // 导出路径
+	FastMode       bool   `json:"fastMode"`       // 快速模式：只测试延迟
+	RenameNodes    bool   `json:"renameNodes"`    // 节点重命名：按出口 IP 的地理位置重命名
+	RenameTemplate string `json:"renameTemplate"` // 重命名模板，为空时使用默认模板
+	ExportFormat   string `json:"exportFormat"`   // 导出格式：json, csv, yaml, clash
+	ExportPath     string `json:"exportPath"`     // 导出路径
 	// 解锁检测相关字段
 	TestMode         string   `json:"testMode"`         // 测试模式：speed_only, unlock_only, both
 	UnlockEnabled    bool     `json:"unlockEnabled"`    // 是否启用解锁检测
@@ -28,6 +42,43 @@ type TestRequest struct {
 	UnlockConcurrent int      `json:"unlockConcurrent"` // 解锁检测并发数
 	UnlockTimeout    int      `json:"unlockTimeout"`    // 解锁检测超时时间
 	UnlockRetry      bool     `json:"unlockRetry"`      // 解锁检测失败时是否重试
+	// 出口探测相关字段
+	EgressProbe       bool     `json:"egressProbe"`       // 通过代理探测出口 IPv4/IPv6 并识别中转
+	EgressEndpoints   []string `json:"egressEndpoints"`   // IPv4 回显服务，返回纯文本 IP
+	EgressEndpointsV6 []string `json:"egressEndpointsV6"` // IPv6 回显服务
+	// 双栈探测相关字段
+	DualStack   bool   `json:"dualStack"`   // 延迟测试时分别探测 IPv4 与 IPv6 连通性
+	RequireIPv6 bool   `json:"requireIPv6"` // 要求节点支持 IPv6（自动开启双栈探测）
+	IPv4Target  string `json:"ipv4Target"`  // 仅 IPv4 可达的探测 URL，主机须为 IPv4 字面量
+	IPv6Target  string `json:"ipv6Target"`  // 仅 IPv6 可达的探测 URL，主机须为 IPv6 字面量
+	// UDP 测试相关字段
+	UDPServer  string `json:"udpServer"`  // UDP 回显服务地址（host:port），为空时不测试
+	UDPPackets int    `json:"udpPackets"` // 发送的数据包数，默认 10
+	// NAT 类型检测相关字段
+	STUNServer string `json:"stunServer"` // 支持 RFC 5780 的 STUN 服务地址（host:port），为空时不检测
+	// 延迟测试相关字段
+	PingCount    int    `json:"pingCount"`    // 每个节点的延迟测试请求次数，默认 6（VLESS 节点默认 3）
+	PingInterval int    `json:"pingInterval"` // 请求间隔（毫秒），默认 100
+	LatencyURL   string `json:"latencyUrl"`   // 延迟测试地址，为空时由 backend 决定（Cloudflare 协议为 /__down?bytes=0）
+	// 并列延迟探测相关字段
+	LatencyProbes []string `json:"latencyProbes"` // 探测方式：http（与 mihomo url-test 一致）、tcp、tls
+	ProbeURL      string   `json:"probeUrl"`      // 探测地址，默认 https://www.gstatic.com/generate_204
+	ProbeStatus   string   `json:"probeStatus"`   // http 探测期望的状态码，如 "204" 或 "200-299"，为空时接受任意状态
+	// 定时测速相关字段
+	TestDuration   int `json:"testDuration"`   // 每个方向的测速时长（秒），为 0 时按 downloadSize/uploadSize 传输固定字节数
+	SampleInterval int `json:"sampleInterval"` // 采样周期（毫秒），100-250，默认 200
+	// 多连接饱和测试相关字段
+	SaturationStreams int `json:"saturationStreams"` // 最大并发连接数，从 1 开始翻倍直到该值，为 0 时不测试
+	SaturationStage   int `json:"saturationStage"`   // 每个阶段的时长（秒），默认 3
+	// 负载下延迟相关字段
+	Bufferbloat bool `json:"bufferbloat"` // 带宽测试期间持续测量延迟并评定 bufferbloat 等级
+	// 带宽测试后端相关字段
+	Backend string `json:"backend"` // cloudflare（默认）、download-server、librespeed 或 file（serverUrl 为大文件地址，仅测下载）
+	// 多服务器带宽测试相关字段
+	BandwidthServers []string `json:"bandwidthServers"` // 多个带宽测试服务器（均使用 backend 协议），为空时只使用 serverUrl
+	ServerSelection  string   `json:"serverSelection"`  // nearest（默认，按节点到各服务器的延迟选择最近的）或 all（测试全部，取下载最快的）
+	// 数据完整性校验相关字段
+	VerifyPayload bool `json:"verifyPayload"` // 使用种子伪随机数据测速并校验，损坏或截断的方向不报告速度（下载校验需要 download-server 后端）
 }
 
 // SetRequestDefaults 设置请求默认值
@@ -50,6 +101,12 @@ func SetRequestDefaults(req *TestRequest) {
 	if req.Concurrent == 0 {
 		req.Concurrent = 4
 	}
+	if req.NodeConcurrent == 0 {
+		req.NodeConcurrent = 4
+	}
+	if req.MaxSpeedTests == 0 {
+		req.MaxSpeedTests = 1
+	}
 	if req.MaxLatency == 0 {
 		req.MaxLatency = 800
 	}
@@ -75,6 +132,15 @@ func ValidateRequest(req *TestRequest) error {
 	if req.Concurrent < 1 || req.Concurrent > 100 {
 		return NewValidationError("concurrent must be between 1 and 100")
 	}
+	if req.NodeConcurrent < 1 || req.NodeConcurrent > 64 {
+		return NewValidationError("node concurrent must be between 1 and 64")
+	}
+	if req.MaxSpeedTests < 1 || req.MaxSpeedTests > req.NodeConcurrent {
+		return NewValidationError("max speed tests must be between 1 and node concurrent")
+	}
+	if req.PipelineTopN < 0 {
+		return NewValidationError("pipeline top n must be non-negative")
+	}
 	if req.Timeout < 1 || req.Timeout > 300 {
 		return NewValidationError("timeout must be between 1 and 300 seconds")
 	}
@@ -99,10 +165,113 @@ func ValidateRequest(req *TestRequest) error {
 	if !validMode {
 		return NewValidationError("test mode must be one of: speed_only, unlock_only, both")
 	}
+
+	for _, endpoint := range append(append([]string{}, req.EgressEndpoints...), req.EgressEndpointsV6...) {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return NewValidationError("egress endpoint must be an http(s) URL: " + endpoint)
+		}
+	}
+
+	if req.IPv4Target != "" && !isIPLiteralURL(req.IPv4Target, true) {
+		return NewValidationError("ipv4 target must be an http(s) URL with an IPv4 address host: " + req.IPv4Target)
+	}
+	if req.IPv6Target != "" && !isIPLiteralURL(req.IPv6Target, false) {
+		return NewValidationError("ipv6 target must be an http(s) URL with an IPv6 address host: " + req.IPv6Target)
+	}
+
+	if req.UDPServer != "" {
+		if _, port, err := net.SplitHostPort(req.UDPServer); err != nil || port == "" {
+			return NewValidationError("udp server must be in host:port form: " + req.UDPServer)
+		}
+	}
+	if req.UDPPackets < 0 || req.UDPPackets > 100 {
+		return NewValidationError("udp packets must be between 0 and 100")
+	}
+
+	if req.STUNServer != "" {
+		if _, port, err := net.SplitHostPort(req.STUNServer); err != nil || port == "" {
+			return NewValidationError("stun server must be in host:port form: " + req.STUNServer)
+		}
+	}
+
+	if req.PingCount < 0 || req.PingCount > 100 {
+		return NewValidationError("ping count must be between 0 and 100")
+	}
+	if req.PingInterval < 0 || req.PingInterval > 10000 {
+		return NewValidationError("ping interval must be between 0 and 10000 ms")
+	}
+	if req.LatencyURL != "" {
+		if u, err := url.Parse(req.LatencyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return NewValidationError("latency url must be an http(s) URL: " + req.LatencyURL)
+		}
+	}
+
+	if req.TestDuration < 0 || req.TestDuration > 60 {
+		return NewValidationError("test duration must be between 0 and 60 seconds")
+	}
+	if req.SampleInterval != 0 && (req.SampleInterval < 100 || req.SampleInterval > 250) {
+		return NewValidationError("sample interval must be between 100 and 250 ms")
+	}
+
+	validBackends := []string{"cloudflare", "download-server", "file", "librespeed"}
+	if req.Backend != "" && !slices.Contains(validBackends, req.Backend) {
+		return NewValidationError("backend must be one of: cloudflare, download-server, file, librespeed")
+	}
+	if u, err := url.Parse(req.ServerURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return NewValidationError("server url must be an http(s) URL: " + req.ServerURL)
+	}
+	if len(req.BandwidthServers) > 10 {
+		return NewValidationError("at most 10 bandwidth servers are allowed")
+	}
+	for _, server := range req.BandwidthServers {
+		if u, err := url.Parse(server); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return NewValidationError("bandwidth server must be an http(s) URL: " + server)
+		}
+	}
+	if req.ServerSelection != "" && req.ServerSelection != "nearest" && req.ServerSelection != "all" {
+		return NewValidationError("server selection must be one of: nearest, all")
+	}
+
+	if req.SaturationStreams < 0 || req.SaturationStreams > 32 {
+		return NewValidationError("saturation streams must be between 0 and 32")
+	}
+	if req.SaturationStage < 0 || req.SaturationStage > 30 {
+		return NewValidationError("saturation stage must be between 0 and 30 seconds")
+	}
+
+	validProbes := []string{"http", "tcp", "tls"}
+	for _, probe := range req.LatencyProbes {
+		if !slices.Contains(validProbes, probe) {
+			return NewValidationError("invalid latency probe: " + probe)
+		}
+	}
+	if req.ProbeURL != "" {
+		if u, err := url.Parse(req.ProbeURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return NewValidationError("probe url must be an http(s) URL: " + req.ProbeURL)
+		}
+	}
+	if req.ProbeStatus != "" {
+		if _, err := utils.NewUnsignedRanges[uint16](req.ProbeStatus); err != nil {
+			return NewValidationError("invalid probe status: " + req.ProbeStatus)
+		}
+	}
 	
 	return nil
 }
 
+// isIPLiteralURL 判断 URL 是否为主机为指定协议族 IP 字面量的 http(s) 地址
+func isIPLiteralURL(rawURL string, ipv4 bool) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	addr, err := netip.ParseAddr(u.Hostname())
+	if err != nil {
+		return false
+	}
+	return addr.Is4() == ipv4
+}
+
 // ValidationError 验证错误类型
 type ValidationError struct {
 	Message string
@@ -2,25 +2,37 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"path/filepath"
 
+	"github.com/zhsama/clash-speedtest/config"
 	"github.com/zhsama/clash-speedtest/logger"
 	"github.com/zhsama/clash-speedtest/server/response"
 	"github.com/zhsama/clash-speedtest/speedtester"
+	"github.com/zhsama/clash-speedtest/tasks"
 	"github.com/zhsama/clash-speedtest/unlock"
 	"github.com/zhsama/clash-speedtest/utils/export"
+	"github.com/zhsama/clash-speedtest/utils/geo"
 )
 
 // ConfigHandler 配置处理器
 type ConfigHandler struct {
 	*Handler
+	taskManager  *tasks.Manager
+	exportConfig config.ExportConfig
+	locations    *geo.LocationCache
 }
 
 // NewConfigHandler 创建新的配置处理器
-func NewConfigHandler() *ConfigHandler {
+func NewConfigHandler(taskManager *tasks.Manager, exportConfig config.ExportConfig) *ConfigHandler {
 	return &ConfigHandler{
-		Handler: NewHandler(),
+		Handler:      NewHandler(),
+		taskManager:  taskManager,
+		exportConfig: exportConfig,
+		locations:    geo.Default(),
 	}
 }
 
@@ -159,7 +171,7 @@ func (h *ConfigHandler) HandleGetNodes(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// HandleExportResults 处理导出结果请求
+// HandleExportResults 处理导出结果请求，将任务结果以文件下载形式返回
 func (h *ConfigHandler) HandleExportResults(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	
@@ -169,8 +181,9 @@ func (h *ConfigHandler) HandleExportResults(w http.ResponseWriter, r *http.Reque
 	}
 	
 	var exportReq struct {
-		TaskID  string                 `json:"taskId"`
-		Options export.ExportOptions `json:"options"`
+		TaskID     string               `json:"taskId"`
+		Options    export.ExportOptions `json:"options"`
+		ResolveGeo bool                 `json:"resolveGeo"` // 是否查询节点地理位置
 	}
 	
 	if err := json.NewDecoder(r.Body).Decode(&exportReq); err != nil {
@@ -178,21 +191,95 @@ func (h *ConfigHandler) HandleExportResults(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	
+	if exportReq.TaskID == "" {
+		response.SendError(ctx, w, http.StatusBadRequest, "Task ID is required")
+		return
+	}
+	
 	// 验证导出选项
 	if err := export.ValidateExportOptions(exportReq.Options); err != nil {
 		response.SendError(ctx, w, http.StatusBadRequest, "Invalid export options: "+err.Error())
 		return
 	}
 	
-	// TODO: 实现实际的导出逻辑
-	// 这里需要从任务管理器中获取测试结果，然后进行导出
+	// 写入服务器路径需要显式开启，且只能写入导出目录
+	if exportReq.Options.OutputPath != "" {
+		if !h.exportConfig.AllowServerWrite {
+			response.SendError(ctx, w, http.StatusForbidden, "Saving exports on the server is disabled")
+			return
+		}
+		exportReq.Options.OutputPath = filepath.Join(h.exportConfig.Dir, filepath.Base(exportReq.Options.OutputPath))
+	}
 	
-	response.SendJSON(ctx, w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"message": "Export functionality will be implemented",
-		"format":  exportReq.Options.Format,
-		"path":    exportReq.Options.OutputPath,
-	})
+	task, exists := h.taskManager.GetTask(exportReq.TaskID)
+	if !exists {
+		response.HandleError(ctx, w, response.NewNotFoundError("Task not found: "+exportReq.TaskID))
+		return
+	}
+	
+	var lookup func(server string) *geo.GeoLocation
+	if exportReq.ResolveGeo {
+		lookup = h.locations.Lookup
+	}
+	exporter := newTaskExporter(task, lookup)
+	
+	// 同时写入服务器文件时先创建文件，失败时仍可返回错误状态
+	var out io.Writer = w
+	if exportReq.Options.OutputPath != "" {
+		file, err := export.CreateOutputFile(exportReq.Options)
+		if err != nil {
+			logger.LogError("Failed to save export file", err,
+				slog.String("task_id", exportReq.TaskID),
+				slog.String("path", exportReq.Options.OutputPath))
+			response.SendError(ctx, w, http.StatusInternalServerError, "Failed to save export file: "+err.Error())
+			return
+		}
+		defer file.Close()
+		out = io.MultiWriter(w, file)
+	}
+	
+	filename := export.GenerateFilename("clash-speedtest_"+exportReq.TaskID, exportReq.Options.Format)
+	
+	// 结果直接流式写入响应，响应头发出后出错只能记录日志
+	w.Header().Set("Content-Type", export.ContentType(exportReq.Options.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	if err := exporter.ExportTo(out, exportReq.Options); err != nil {
+		logger.LogError("Failed to export results", err, slog.String("task_id", exportReq.TaskID))
+		return
+	}
+	
+	if exportReq.Options.OutputPath != "" {
+		logger.Logger.InfoContext(ctx, "Export file saved on server",
+			slog.String("task_id", exportReq.TaskID),
+			slog.String("path", exportReq.Options.OutputPath))
+	}
+	logger.Logger.InfoContext(ctx, "Results exported",
+		slog.String("task_id", exportReq.TaskID),
+		slog.String("format", string(exportReq.Options.Format)))
+}
+
+// newTaskExporter 将任务结果转换为可导出的结果，lookup 为 nil 时不查询地理位置
+func newTaskExporter(task tasks.Task, lookup func(server string) *geo.GeoLocation) *export.Exporter {
+	exporter := export.NewExporter()
+	snapshot := task.Snapshot()
+	
+	testTime := snapshot.StartTime
+	if !snapshot.CompleteTime.IsZero() {
+		testTime = snapshot.CompleteTime
+	}
+	
+	for _, result := range task.Results() {
+		var location *geo.GeoLocation
+		if lookup != nil && result.ProxyIP != "" {
+			location = lookup(result.ProxyIP)
+		}
+		
+		status := tasks.DetermineResultStatus(result, snapshot.Config)
+		exporter.AddResult(export.FromSpeedtestResult(result, status, testTime, location))
+	}
+	
+	return exporter
 }
 
 // HandleGetUnlockPlatforms 处理获取支持的解锁检测平台请求
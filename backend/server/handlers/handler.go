@@ -3,15 +3,13 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"log/slog"
 	"net/http"
-	"time"
 
 	"github.com/zhsama/clash-speedtest/logger"
 	"github.com/zhsama/clash-speedtest/server/common"
 	"github.com/zhsama/clash-speedtest/server/response"
 	"github.com/zhsama/clash-speedtest/speedtester"
-	"github.com/zhsama/clash-speedtest/unlock"
+	"github.com/zhsama/clash-speedtest/tasks"
 )
 
 // Handler 处理器基础结构
@@ -42,82 +40,9 @@ func (h *Handler) parseTestRequest(r *http.Request) (*common.TestRequest, error)
 	return &req, nil
 }
 
-// createUnlockConfig 根据TestRequest创建解锁检测配置
-func (h *Handler) createUnlockConfig(req *common.TestRequest) *unlock.UnlockTestConfig {
-	logger.Logger.DebugContext(context.Background(), "Creating unlock config",
-		slog.Bool("unlock_enabled", req.UnlockEnabled),
-		slog.String("test_mode", req.TestMode),
-		slog.Any("platforms", req.UnlockPlatforms),
-		slog.Int("concurrent", req.UnlockConcurrent),
-		slog.Int("timeout", req.UnlockTimeout),
-	)
-
-	needsUnlock := req.TestMode == "unlock_only" || req.TestMode == "both"
-	
-	if !req.UnlockEnabled && !needsUnlock {
-		logger.Logger.DebugContext(context.Background(), "Unlock detection disabled by request")
-		return &unlock.UnlockTestConfig{
-			Enabled: false,
-		}
-	}
-
-	if needsUnlock {
-		logger.Logger.DebugContext(context.Background(), "Auto-enabling unlock detection for test mode", 
-			slog.String("test_mode", req.TestMode))
-	}
-
-	platforms := req.UnlockPlatforms
-	if len(platforms) == 0 {
-		platforms = []string{"Netflix", "YouTube", "Disney+", "ChatGPT", "Spotify", "Bilibili"}
-		logger.Logger.DebugContext(context.Background(), "Using default platforms", 
-			slog.Any("platforms", platforms))
-	}
-
-	concurrent := req.UnlockConcurrent
-	if concurrent <= 0 {
-		concurrent = 5
-	}
-
-	timeout := req.UnlockTimeout
-	if timeout <= 0 {
-		timeout = 10
-	}
-
-	config := &unlock.UnlockTestConfig{
-		Enabled:       true,
-		Platforms:     platforms,
-		Concurrent:    concurrent,
-		Timeout:       timeout,
-		RetryOnError:  req.UnlockRetry,
-		IncludeIPInfo: true,
-	}
-
-	return config
-}
-
 // createSpeedTester 创建速度测试器
 func (h *Handler) createSpeedTester(req *common.TestRequest) *speedtester.SpeedTester {
-	unlockConfig := h.createUnlockConfig(req)
-	
-	return speedtester.New(&speedtester.Config{
-		ConfigPaths:      req.ConfigPaths,
-		FilterRegex:      req.FilterRegex,
-		IncludeNodes:     req.IncludeNodes,
-		ExcludeNodes:     req.ExcludeNodes,
-		ProtocolFilter:   req.ProtocolFilter,
-		ServerURL:        req.ServerURL,
-		DownloadSize:     req.DownloadSize * 1024 * 1024,
-		UploadSize:       req.UploadSize * 1024 * 1024,
-		Timeout:          time.Duration(req.Timeout) * time.Second,
-		Concurrent:       req.Concurrent,
-		MaxLatency:       time.Duration(req.MaxLatency) * time.Millisecond,
-		MinDownloadSpeed: req.MinDownloadSpeed * 1024 * 1024,
-		MinUploadSpeed:   req.MinUploadSpeed * 1024 * 1024,
-		FastMode:         req.FastMode,
-		RenameNodes:      req.RenameNodes,
-		TestMode:         req.TestMode,
-		UnlockConfig:     unlockConfig,
-	})
+	return tasks.NewSpeedTester(req)
 }
 
 // handleMethodNotAllowed 处理不允许的方法
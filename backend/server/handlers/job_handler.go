@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/zhsama/clash-speedtest/scheduler"
+	"github.com/zhsama/clash-speedtest/server/common"
+	"github.com/zhsama/clash-speedtest/server/response"
+)
+
+// JobHandler 定时任务处理器
+type JobHandler struct {
+	*Handler
+	scheduler *scheduler.Scheduler
+}
+
+// NewJobHandler 创建新的定时任务处理器
+func NewJobHandler(jobScheduler *scheduler.Scheduler) *JobHandler {
+	return &JobHandler{
+		Handler:   NewHandler(),
+		scheduler: jobScheduler,
+	}
+}
+
+// HandleJobs 处理定时任务列表和创建请求
+func (h *JobHandler) HandleJobs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		jobs := h.scheduler.ListJobs()
+		response.SendSuccess(ctx, w, map[string]interface{}{
+			"jobs":  jobs,
+			"total": len(jobs),
+		})
+	case http.MethodPost:
+		spec, ok := h.parseJobSpec(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		job, err := h.scheduler.CreateJob(spec)
+		if err != nil {
+			h.sendJobError(ctx, w, err)
+			return
+		}
+		response.SendSuccess(ctx, w, job)
+	default:
+		h.handleMethodNotAllowed(ctx, w, r, "GET", "POST")
+	}
+}
+
+// HandleJob 处理单个定时任务的查询、更新和删除请求
+func (h *JobHandler) HandleJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jobID := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		job, exists := h.scheduler.GetJob(jobID)
+		if !exists {
+			response.HandleError(ctx, w, response.NewNotFoundError("Job not found: "+jobID))
+			return
+		}
+		response.SendSuccess(ctx, w, job)
+	case http.MethodPut:
+		if _, exists := h.scheduler.GetJob(jobID); !exists {
+			response.HandleError(ctx, w, response.NewNotFoundError("Job not found: "+jobID))
+			return
+		}
+
+		spec, ok := h.parseJobSpec(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		job, err := h.scheduler.UpdateJob(jobID, spec)
+		if err != nil {
+			h.sendJobError(ctx, w, err)
+			return
+		}
+		response.SendSuccess(ctx, w, job)
+	case http.MethodDelete:
+		if err := h.scheduler.DeleteJob(jobID); err != nil {
+			response.HandleError(ctx, w, response.NewNotFoundError(err.Error()))
+			return
+		}
+		response.SendSuccess(ctx, w, map[string]interface{}{
+			"jobId":   jobID,
+			"message": "Job deleted",
+		})
+	default:
+		h.handleMethodNotAllowed(ctx, w, r, "GET", "PUT", "DELETE")
+	}
+}
+
+// HandleRunJob 处理立即运行定时任务请求
+func (h *JobHandler) HandleRunJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Method != http.MethodPost {
+		h.handleMethodNotAllowed(ctx, w, r, "POST")
+		return
+	}
+
+	jobID := r.PathValue("id")
+	job, err := h.scheduler.RunJob(jobID)
+	if err != nil {
+		response.HandleError(ctx, w, response.NewNotFoundError(err.Error()))
+		return
+	}
+
+	logger.Logger.InfoContext(ctx, "Scheduled job triggered manually", slog.String("job_id", jobID))
+	response.SendSuccess(ctx, w, job)
+}
+
+// parseJobSpec 解析定时任务参数
+func (h *JobHandler) parseJobSpec(ctx context.Context, w http.ResponseWriter, r *http.Request) (*scheduler.JobSpec, bool) {
+	var spec scheduler.JobSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		response.SendError(ctx, w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return nil, false
+	}
+	return &spec, true
+}
+
+// sendJobError 校验错误返回 400，其余返回 500
+func (h *JobHandler) sendJobError(ctx context.Context, w http.ResponseWriter, err error) {
+	var validationErr *common.ValidationError
+	if errors.As(err, &validationErr) {
+		response.SendError(ctx, w, http.StatusBadRequest, validationErr.Message)
+		return
+	}
+
+	logger.LogError("Scheduled job operation failed", err)
+	response.SendError(ctx, w, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zhsama/clash-speedtest/config"
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/zhsama/clash-speedtest/scheduler"
+	"github.com/zhsama/clash-speedtest/server/response"
+	"github.com/zhsama/clash-speedtest/tasks"
+	"github.com/zhsama/clash-speedtest/utils/export"
+	"github.com/zhsama/clash-speedtest/utils/geo"
+)
+
+// Subscription source constants
+const (
+	SourceLatest    = "latest"
+	SourceJobPrefix = "job:"
+)
+
+// SubscriptionHandler 订阅处理器，以 Clash 配置形式提供最近一次测试中的可用节点
+type SubscriptionHandler struct {
+	*Handler
+	taskManager *tasks.Manager
+	scheduler   *scheduler.Scheduler
+	profiles    map[string]config.SubscriptionProfile
+	locations   *geo.LocationCache
+}
+
+// NewSubscriptionHandler 创建新的订阅处理器，无效的配置会被跳过
+func NewSubscriptionHandler(taskManager *tasks.Manager, jobScheduler *scheduler.Scheduler, subscriptionConfig config.SubscriptionConfig) *SubscriptionHandler {
+	profiles := make(map[string]config.SubscriptionProfile)
+	for _, profile := range subscriptionConfig.Profiles {
+		if err := validateProfile(profile); err != nil {
+			logger.LogError("Skipping invalid subscription profile", err, slog.String("profile", profile.Name))
+			continue
+		}
+		profiles[profile.Name] = profile
+	}
+
+	return &SubscriptionHandler{
+		Handler:     NewHandler(),
+		taskManager: taskManager,
+		scheduler:   jobScheduler,
+		profiles:    profiles,
+		locations:   geo.Default(),
+	}
+}
+
+// HandleSubscription 处理订阅请求 GET /sub/{profile}.yaml
+func (h *SubscriptionHandler) HandleSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Method != http.MethodGet {
+		h.handleMethodNotAllowed(ctx, w, r, "GET")
+		return
+	}
+
+	name := strings.TrimSuffix(strings.TrimSuffix(r.PathValue("profile"), ".yaml"), ".yml")
+	profile, exists := h.profiles[name]
+	if !exists {
+		response.HandleError(ctx, w, response.NewNotFoundError("Subscription profile not found: "+name))
+		return
+	}
+
+	if profile.Token != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(profile.Token)) != 1 {
+		response.SendError(ctx, w, http.StatusUnauthorized, "Invalid subscription token")
+		return
+	}
+
+	task, err := h.resolveSource(profile.Source)
+	if err != nil {
+		response.HandleError(ctx, w, response.NewNotFoundError(err.Error()))
+		return
+	}
+
+	var lookup func(server string) *geo.GeoLocation
+	if profile.ResolveGeo {
+		lookup = h.locations.Lookup
+	}
+	exporter := newTaskExporter(task, lookup)
+
+	var buf bytes.Buffer
+	if err := exporter.ExportTo(&buf, profileExportOptions(profile)); err != nil {
+		logger.LogError("Failed to render subscription", err, slog.String("profile", name))
+		response.SendError(ctx, w, http.StatusInternalServerError, "Failed to render subscription: "+err.Error())
+		return
+	}
+
+	logger.Logger.InfoContext(ctx, "Subscription served",
+		slog.String("profile", name),
+		slog.String("task_id", task.ID()))
+
+	w.Header().Set("Content-Type", "text/yaml; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", name+".yaml"))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// resolveSource 查找订阅来源对应的已完成任务
+func (h *SubscriptionHandler) resolveSource(source string) (tasks.Task, error) {
+	if ref, ok := strings.CutPrefix(source, SourceJobPrefix); ok {
+		job, exists := h.scheduler.FindJob(ref)
+		if !exists {
+			return nil, fmt.Errorf("scheduled job not found: %s", ref)
+		}
+		if job.LastSuccess == nil {
+			return nil, fmt.Errorf("scheduled job %s has no completed run yet", ref)
+		}
+
+		task, exists := h.taskManager.GetTask(job.LastSuccess.TaskID)
+		if !exists {
+			return nil, fmt.Errorf("results of scheduled job %s are no longer available", ref)
+		}
+		return task, nil
+	}
+
+	// GetAllTasks 按开始时间倒序返回
+	for _, task := range h.taskManager.GetAllTasks() {
+		// 仅解锁检测的任务没有测速结果
+		if task.Type() != tasks.TaskTypeUnlock && task.Status() == tasks.TaskStatusCompleted {
+			return task, nil
+		}
+	}
+	return nil, fmt.Errorf("no completed test results available")
+}
+
+// profileExportOptions 将订阅配置转换为导出选项
+func profileExportOptions(profile config.SubscriptionProfile) export.ExportOptions {
+	sortBy := profile.SortBy
+	if sortBy == "" {
+		sortBy = "latency"
+	}
+
+	groups := make([]export.ProxyGroupTemplate, 0, len(profile.Groups))
+	for _, group := range profile.Groups {
+		groups = append(groups, export.ProxyGroupTemplate{
+			Name:        group.Name,
+			Type:        group.Type,
+			Members:     group.Members,
+			PerCountry:  group.PerCountry,
+			PerPlatform: group.PerPlatform,
+			Platforms:   group.Platforms,
+			Proxies:     group.Proxies,
+			URL:         group.URL,
+			Interval:    group.Interval,
+			Tolerance:   group.Tolerance,
+			Strategy:    group.Strategy,
+		})
+	}
+
+	return export.ExportOptions{
+		Format:            export.FormatClash,
+		SortBy:            sortBy,
+		TopN:              profile.TopN,
+		MaxLatency:        profile.MaxLatency,
+		MinDownload:       profile.MinDownload,
+		MinUpload:         profile.MinUpload,
+		RequiredPlatforms: profile.RequireUnlock,
+		ASNTypes:          profile.ASNTypes,
+		ProxyGroups:       groups,
+		KeepProxyNames:    profile.KeepNames,
+	}
+}
+
+// validateProfile 校验订阅配置
+func validateProfile(profile config.SubscriptionProfile) error {
+	if profile.Name == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if profile.Source != "" && profile.Source != SourceLatest && !strings.HasPrefix(profile.Source, SourceJobPrefix) {
+		return fmt.Errorf("unsupported source %q, expected %q or %q", profile.Source, SourceLatest, SourceJobPrefix+"<job>")
+	}
+	return export.ValidateExportOptions(profileExportOptions(profile))
+}
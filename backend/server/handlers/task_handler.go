@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/zhsama/clash-speedtest/server/response"
+	"github.com/zhsama/clash-speedtest/tasks"
+	"github.com/zhsama/clash-speedtest/websocket"
+)
+
+// TaskHandler 任务处理器
+type TaskHandler struct {
+	*Handler
+	taskManager *tasks.Manager
+}
+
+// NewTaskHandler 创建新的任务处理器
+func NewTaskHandler(taskManager *tasks.Manager) *TaskHandler {
+	return &TaskHandler{
+		Handler:     NewHandler(),
+		taskManager: taskManager,
+	}
+}
+
+// HandleListTasks 处理任务列表请求
+func (h *TaskHandler) HandleListTasks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Method != http.MethodGet {
+		h.handleMethodNotAllowed(ctx, w, r, "GET")
+		return
+	}
+
+	allTasks := h.taskManager.GetAllTasks()
+	summaries := make([]*tasks.TaskResult, 0, len(allTasks))
+	for _, task := range allTasks {
+		// 列表中不返回完整结果，避免响应过大
+		snapshot := task.Snapshot()
+		snapshot.Results = nil
+		summaries = append(summaries, snapshot)
+	}
+
+	response.SendSuccess(ctx, w, map[string]interface{}{
+		"tasks": summaries,
+		"total": len(summaries),
+		"stats": h.taskManager.GetStats(),
+	})
+}
+
+// HandleTask 处理单个任务的查询和删除请求
+func (h *TaskHandler) HandleTask(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		h.handleGetTask(ctx, w, r.PathValue("id"))
+	case http.MethodDelete:
+		h.handleDeleteTask(ctx, w, r.PathValue("id"))
+	default:
+		h.handleMethodNotAllowed(ctx, w, r, "GET", "DELETE")
+	}
+}
+
+// handleGetTask 返回任务详情、进度及结果
+func (h *TaskHandler) handleGetTask(ctx context.Context, w http.ResponseWriter, taskID string) {
+	task, exists := h.taskManager.GetTask(taskID)
+	if !exists {
+		response.HandleError(ctx, w, response.NewNotFoundError("Task not found: "+taskID))
+		return
+	}
+
+	response.SendSuccess(ctx, w, task.Snapshot())
+}
+
+// handleDeleteTask 删除任务，运行中的任务会先被取消
+func (h *TaskHandler) handleDeleteTask(ctx context.Context, w http.ResponseWriter, taskID string) {
+	task, exists := h.taskManager.GetTask(taskID)
+	if !exists {
+		response.HandleError(ctx, w, response.NewNotFoundError("Task not found: "+taskID))
+		return
+	}
+
+	if task.Status() == tasks.TaskStatusRunning {
+		task.Cancel()
+	}
+
+	if err := h.taskManager.RemoveTask(taskID); err != nil {
+		response.HandleError(ctx, w, response.NewNotFoundError(err.Error()))
+		return
+	}
+
+	logger.Logger.InfoContext(ctx, "Task deleted", slog.String("task_id", taskID))
+
+	response.SendSuccess(ctx, w, map[string]interface{}{
+		"taskId":  taskID,
+		"message": "Task deleted",
+	})
+}
+
+// HandleCancelTask 处理取消任务请求
+func (h *TaskHandler) HandleCancelTask(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Method != http.MethodPost {
+		h.handleMethodNotAllowed(ctx, w, r, "POST")
+		return
+	}
+
+	taskID := r.PathValue("id")
+	if _, exists := h.taskManager.GetTask(taskID); !exists {
+		response.HandleError(ctx, w, response.NewNotFoundError("Task not found: "+taskID))
+		return
+	}
+
+	if err := h.taskManager.CancelTask(taskID); err != nil {
+		response.SendError(ctx, w, http.StatusConflict, "Failed to cancel task: "+err.Error())
+		return
+	}
+
+	logger.Logger.InfoContext(ctx, "Task cancelled by request", slog.String("task_id", taskID))
+
+	response.SendSuccess(ctx, w, map[string]interface{}{
+		"taskId":  taskID,
+		"message": "Task cancelled",
+	})
+}
+
+// HandleWebSocketMessage 处理客户端通过 WebSocket 发送的消息
+func (h *TaskHandler) HandleWebSocketMessage(msgType string, data []byte) {
+	if websocket.MessageType(msgType) != websocket.MessageTypeStopTest {
+		return
+	}
+
+	var msg struct {
+		TaskID string `json:"taskId"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil || msg.TaskID == "" {
+		logger.Logger.Warn("Ignoring stop_test message without task ID")
+		return
+	}
+
+	if err := h.taskManager.CancelTask(msg.TaskID); err != nil {
+		logger.Logger.Warn("Failed to cancel task from WebSocket",
+			slog.String("task_id", msg.TaskID),
+			slog.String("error", err.Error()))
+		return
+	}
+
+	logger.Logger.Info("Task cancelled via WebSocket", slog.String("task_id", msg.TaskID))
+}
@@ -1,59 +1,32 @@
 package handlers
 
 import (
-	"context"
-	"fmt"
 	"log/slog"
 	"net/http"
 	"sort"
-	"sync"
 	"time"
 
 	"github.com/zhsama/clash-speedtest/logger"
 	"github.com/zhsama/clash-speedtest/server/common"
 	"github.com/zhsama/clash-speedtest/server/response"
 	"github.com/zhsama/clash-speedtest/speedtester"
-	"github.com/zhsama/clash-speedtest/websocket"
+	"github.com/zhsama/clash-speedtest/tasks"
 )
 
 // TestHandler 测试处理器
 type TestHandler struct {
 	*Handler
-	wsHub *websocket.Hub
-	
-	// 任务管理
-	testTasks      map[string]*TestTask
-	testTasksMutex sync.RWMutex
-	
-	// 全局测试取消
-	testCancelFunc context.CancelFunc
-	testCancelMutex sync.RWMutex
-}
-
-// TestTask 测试任务结构
-type TestTask struct {
-	ID         string
-	Config     *common.TestRequest
-	Context    context.Context
-	CancelFunc context.CancelFunc
-	Status     string // pending, running, completed, cancelled
-	StartTime  time.Time
+	taskManager *tasks.Manager
 }
 
 // NewTestHandler 创建新的测试处理器
-func NewTestHandler(wsHub *websocket.Hub) *TestHandler {
+func NewTestHandler(taskManager *tasks.Manager) *TestHandler {
 	return &TestHandler{
-		Handler:   NewHandler(),
-		wsHub:     wsHub,
-		testTasks: make(map[string]*TestTask),
+		Handler:     NewHandler(),
+		taskManager: taskManager,
 	}
 }
 
-// generateTaskID 生成任务 ID
-func (h *TestHandler) generateTaskID() string {
-	return fmt.Sprintf("task-%d-%s", time.Now().Unix(), time.Now().Format("150405"))
-}
-
 // HandleTest 处理同步测试请求
 func (h *TestHandler) HandleTest(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -80,6 +53,7 @@ func (h *TestHandler) HandleTest(w http.ResponseWriter, r *http.Request) {
 		slog.Int("download_size_mb", req.DownloadSize),
 		slog.Int("upload_size_mb", req.UploadSize),
 		slog.Int("concurrent", req.Concurrent),
+		slog.Int("node_concurrent", req.NodeConcurrent),
 		slog.Bool("stash_compatible", req.StashCompatible),
 	)
 	
@@ -166,131 +140,20 @@ func (h *TestHandler) HandleTestAsync(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// 创建任务
-	taskID := h.generateTaskID()
-	taskCtx, cancel := context.WithCancel(context.Background())
-	
-	task := &TestTask{
-		ID:         taskID,
-		Config:     req,
-		Context:    taskCtx,
-		CancelFunc: cancel,
-		Status:     "pending",
-		StartTime:  time.Now(),
-	}
-	
-	h.testTasksMutex.Lock()
-	h.testTasks[taskID] = task
-	h.testTasksMutex.Unlock()
-	
-	// 异步执行测试
-	go h.runTestTask(task)
-	
-	// 返回任务ID
-	response.SendSuccess(ctx, w, map[string]interface{}{
-		"taskId":  taskID,
-		"message": "Test task created successfully",
-	})
-}
-
-// runTestTask 执行测试任务
-func (h *TestHandler) runTestTask(task *TestTask) {
-	ctx := task.Context
-	
-	// 更新任务状态
-	h.testTasksMutex.Lock()
-	task.Status = "running"
-	h.testTasksMutex.Unlock()
-	
-	logger.Logger.InfoContext(ctx, "Starting test task", 
-		slog.String("task_id", task.ID))
-	
-	// 创建速度测试器
-	speedTester := h.createSpeedTester(task.Config)
-	
-	// 加载代理
-	allProxies, err := speedTester.LoadProxies(task.Config.StashCompatible)
+	// 通过任务管理器创建并启动任务
+	task, err := h.taskManager.SubmitTask(req, nil)
 	if err != nil {
-		logger.Logger.ErrorContext(ctx, "Failed to load proxies", 
+		logger.Logger.ErrorContext(ctx, "Failed to start test task",
 			slog.String("error", err.Error()))
-		
-		h.wsHub.BroadcastMessage(websocket.MessageTypeError, websocket.ErrorData{
-			Message: "Failed to load proxies: " + err.Error(),
-			Code:    "PROXY_LOAD_ERROR",
-		})
-		
-		h.testTasksMutex.Lock()
-		task.Status = "failed"
-		h.testTasksMutex.Unlock()
-		return
-	}
-	
-	if len(allProxies) == 0 {
-		h.wsHub.BroadcastMessage(websocket.MessageTypeError, websocket.ErrorData{
-			Message: "No proxies found",
-			Code:    "NO_PROXIES_FOUND",
-		})
-		
-		h.testTasksMutex.Lock()
-		task.Status = "failed"
-		h.testTasksMutex.Unlock()
+		response.SendError(ctx, w, http.StatusServiceUnavailable, "Failed to start test task: "+err.Error())
 		return
 	}
 	
-	// 发送测试开始消息
-	h.sendTestStartMessage(task, len(allProxies))
-	
-	// 执行测试
-	results := make([]*speedtester.Result, 0)
-	completed := 0
-	successful := 0
-	failed := 0
-	
-	err = speedTester.TestProxiesWithContext(ctx, allProxies, func(result *speedtester.Result) {
-		results = append(results, result)
-		completed++
-		
-		// 判断结果状态
-		status := h.determineResultStatus(result, task.Config)
-		if status == "success" {
-			successful++
-		} else {
-			failed++
-		}
-		
-		// 发送进度更新
-		h.sendProgressUpdate(task, result, completed, len(allProxies), status)
+	// 返回任务ID
+	response.SendSuccess(ctx, w, map[string]interface{}{
+		"taskId":  task.ID(),
+		"message": "Test task created successfully",
 	})
-	
-	testDuration := time.Since(task.StartTime)
-	
-	// 检查是否被取消
-	if err != nil && err == context.Canceled {
-		logger.Logger.InfoContext(ctx, "Test task cancelled", 
-			slog.String("task_id", task.ID))
-		
-		h.sendTestCancelledMessage(task, completed, len(allProxies), testDuration)
-		
-		h.testTasksMutex.Lock()
-		task.Status = "cancelled"
-		h.testTasksMutex.Unlock()
-		return
-	}
-	
-	// 发送测试完成消息
-	h.sendTestCompleteMessage(task, results, successful, failed, testDuration)
-	
-	h.testTasksMutex.Lock()
-	task.Status = "completed"
-	h.testTasksMutex.Unlock()
-	
-	logger.Logger.InfoContext(ctx, "Test task completed",
-		slog.String("task_id", task.ID),
-		slog.Int("total_tested", len(results)),
-		slog.Int("successful", successful),
-		slog.Int("failed", failed),
-		slog.String("duration", testDuration.String()),
-	)
 }
 
 // filterResults 过滤测试结果
@@ -312,139 +175,3 @@ func (h *TestHandler) filterResults(results []*speedtester.Result, req *common.T
 	
 	return filteredResults
 }
-
-// determineResultStatus 判断结果状态
-func (h *TestHandler) determineResultStatus(result *speedtester.Result, config *common.TestRequest) string {
-	if config.TestMode == "unlock_only" {
-		if result.UnlockSummary.TotalSupported > 0 {
-			return "success"
-		}
-		return "failed"
-	}
-	
-	if result.PacketLoss == 100 || result.Latency > time.Duration(config.MaxLatency)*time.Millisecond {
-		return "failed"
-	}
-	
-	if result.DownloadSpeed < config.MinDownloadSpeed*1024*1024 || result.UploadSpeed < config.MinUploadSpeed*1024*1024 {
-		return "failed"
-	}
-	
-	return "success"
-}
-
-// sendTestStartMessage 发送测试开始消息
-func (h *TestHandler) sendTestStartMessage(task *TestTask, totalProxies int) {
-	testStartData := websocket.TestStartData{
-		TotalProxies: totalProxies,
-	}
-	
-	// 设置配置信息
-	testStartData.Config.ConfigPaths = task.Config.ConfigPaths
-	testStartData.Config.FilterRegex = task.Config.FilterRegex
-	testStartData.Config.ServerURL = task.Config.ServerURL
-	testStartData.Config.DownloadSize = task.Config.DownloadSize
-	testStartData.Config.UploadSize = task.Config.UploadSize
-	testStartData.Config.Timeout = task.Config.Timeout
-	testStartData.Config.Concurrent = task.Config.Concurrent
-	testStartData.Config.MaxLatency = task.Config.MaxLatency
-	testStartData.Config.MinDownloadSpeed = task.Config.MinDownloadSpeed
-	testStartData.Config.MinUploadSpeed = task.Config.MinUploadSpeed
-	testStartData.Config.StashCompatible = task.Config.StashCompatible
-	
-	h.wsHub.BroadcastMessage(websocket.MessageTypeTestStart, testStartData)
-}
-
-// sendProgressUpdate 发送进度更新
-func (h *TestHandler) sendProgressUpdate(task *TestTask, result *speedtester.Result, completed, total int, status string) {
-	progressData := websocket.TestProgressData{
-		CurrentProxy:    result.ProxyName,
-		CompletedCount:  completed,
-		TotalCount:      total,
-		ProgressPercent: float64(completed) / float64(total) * 100,
-		Status:          status,
-	}
-	h.wsHub.BroadcastMessage(websocket.MessageTypeTestProgress, progressData)
-	
-	// 发送单个结果
-	resultData := websocket.TestResultData{
-		ProxyName:         result.ProxyName,
-		ProxyType:         result.ProxyType,
-		ProxyIP:           result.ProxyIP,
-		Latency:           result.Latency.Milliseconds(),
-		Jitter:            result.Jitter.Milliseconds(),
-		PacketLoss:        result.PacketLoss,
-		DownloadSpeed:     result.DownloadSpeed,
-		UploadSpeed:       result.UploadSpeed,
-		DownloadSpeedMbps: result.DownloadSpeed / (1024 * 1024),
-		UploadSpeedMbps:   result.UploadSpeed / (1024 * 1024),
-		Status:            status,
-		UnlockResults:     websocket.ConvertSpeedtesterUnlockResults(result.UnlockResults),
-		UnlockSummary:     websocket.ConvertSpeedtesterUnlockSummary(result.UnlockSummary),
-	}
-	
-	if result.TestError != nil {
-		resultData.ErrorStage = result.TestError.Stage
-		resultData.ErrorCode = result.TestError.Code
-		resultData.ErrorMessage = result.TestError.Message
-	} else if result.FailureStage != "" {
-		resultData.ErrorStage = result.FailureStage
-		resultData.ErrorMessage = result.FailureReason
-	}
-	
-	h.wsHub.BroadcastMessage(websocket.MessageTypeTestResult, resultData)
-}
-
-// sendTestCancelledMessage 发送测试取消消息
-func (h *TestHandler) sendTestCancelledMessage(task *TestTask, completed, total int, duration time.Duration) {
-	cancelData := websocket.TestCancelledData{
-		Message:         "测试已被用户取消",
-		CompletedTests:  completed,
-		TotalTests:      total,
-		PartialDuration: duration.String(),
-	}
-	h.wsHub.BroadcastMessage(websocket.MessageTypeTestCancelled, cancelData)
-}
-
-// sendTestCompleteMessage 发送测试完成消息
-func (h *TestHandler) sendTestCompleteMessage(task *TestTask, results []*speedtester.Result, successful, failed int, duration time.Duration) {
-	var totalLatency, totalDownload, totalUpload float64
-	bestProxy := ""
-	bestDownloadSpeed := 0.0
-	
-	for _, result := range results {
-		if result.PacketLoss < 100 {
-			totalLatency += float64(result.Latency.Milliseconds())
-			totalDownload += result.DownloadSpeed / (1024 * 1024)
-			totalUpload += result.UploadSpeed / (1024 * 1024)
-			
-			downloadMbps := result.DownloadSpeed / (1024 * 1024)
-			if downloadMbps > bestDownloadSpeed {
-				bestDownloadSpeed = downloadMbps
-				bestProxy = result.ProxyName
-			}
-		}
-	}
-	
-	avgLatency := 0.0
-	avgDownload := 0.0
-	avgUpload := 0.0
-	if successful > 0 {
-		avgLatency = totalLatency / float64(successful)
-		avgDownload = totalDownload / float64(successful)
-		avgUpload = totalUpload / float64(successful)
-	}
-	
-	completeData := websocket.TestCompleteData{
-		TotalTested:       len(results),
-		SuccessfulTests:   successful,
-		FailedTests:       failed,
-		TotalDuration:     duration.String(),
-		AverageLatency:    avgLatency,
-		AverageDownload:   avgDownload,
-		AverageUpload:     avgUpload,
-		BestProxy:         bestProxy,
-		BestDownloadSpeed: bestDownloadSpeed,
-	}
-	h.wsHub.BroadcastMessage(websocket.MessageTypeTestComplete, completeData)
-}
\ No newline at end of file
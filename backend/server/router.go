@@ -3,8 +3,11 @@ package server
 import (
 	"net/http"
 
+	"github.com/zhsama/clash-speedtest/config"
+	"github.com/zhsama/clash-speedtest/scheduler"
 	"github.com/zhsama/clash-speedtest/server/handlers"
 	"github.com/zhsama/clash-speedtest/server/middleware"
+	"github.com/zhsama/clash-speedtest/tasks"
 	"github.com/zhsama/clash-speedtest/websocket"
 )
 
@@ -12,17 +15,23 @@ import (
 type Router struct {
 	mux           *http.ServeMux
 	testHandler   *handlers.TestHandler
+	taskHandler   *handlers.TaskHandler
+	jobHandler    *handlers.JobHandler
 	configHandler *handlers.ConfigHandler
+	subHandler    *handlers.SubscriptionHandler
 	systemHandler *handlers.SystemHandler
 	wsHub         *websocket.Hub
 }
 
 // NewRouter 创建新的路由器
-func NewRouter(wsHub *websocket.Hub) *Router {
+func NewRouter(wsHub *websocket.Hub, taskManager *tasks.Manager, jobScheduler *scheduler.Scheduler, appConfig *config.Config) *Router {
 	return &Router{
 		mux:           http.NewServeMux(),
-		testHandler:   handlers.NewTestHandler(wsHub),
-		configHandler: handlers.NewConfigHandler(),
+		testHandler:   handlers.NewTestHandler(taskManager),
+		taskHandler:   handlers.NewTaskHandler(taskManager),
+		jobHandler:    handlers.NewJobHandler(jobScheduler),
+		configHandler: handlers.NewConfigHandler(taskManager, appConfig.Export),
+		subHandler:    handlers.NewSubscriptionHandler(taskManager, jobScheduler, appConfig.Subscription),
 		systemHandler: handlers.NewSystemHandler(),
 		wsHub:         wsHub,
 	}
@@ -44,6 +53,17 @@ func (r *Router) Setup() {
 	r.mux.HandleFunc("/api/test/async", r.withMiddleware(r.testHandler.HandleTestAsync))
 	r.mux.HandleFunc("/test/websocket", r.withMiddleware(r.handleWebSocket))
 	r.mux.HandleFunc("/ws", r.withMiddleware(r.handleWebSocket))
+	r.wsHub.SetMessageHandler(r.taskHandler.HandleWebSocketMessage)
+	
+	// 任务管理相关路由
+	r.mux.HandleFunc("/api/tasks", r.withMiddleware(r.taskHandler.HandleListTasks))
+	r.mux.HandleFunc("/api/tasks/{id}", r.withMiddleware(r.taskHandler.HandleTask))
+	r.mux.HandleFunc("/api/tasks/{id}/cancel", r.withMiddleware(r.taskHandler.HandleCancelTask))
+	
+	// 定时任务相关路由
+	r.mux.HandleFunc("/api/jobs", r.withMiddleware(r.jobHandler.HandleJobs))
+	r.mux.HandleFunc("/api/jobs/{id}", r.withMiddleware(r.jobHandler.HandleJob))
+	r.mux.HandleFunc("/api/jobs/{id}/run", r.withMiddleware(r.jobHandler.HandleRunJob))
 	
 	// 配置相关路由
 	r.mux.HandleFunc("/config/protocols", r.withMiddleware(r.configHandler.HandleGetProtocols))
@@ -51,9 +71,13 @@ func (r *Router) Setup() {
 	r.mux.HandleFunc("/config/nodes", r.withMiddleware(r.configHandler.HandleGetNodes))
 	r.mux.HandleFunc("/api/nodes", r.withMiddleware(r.configHandler.HandleGetNodes))
 	r.mux.HandleFunc("/config/export", r.withMiddleware(r.configHandler.HandleExportResults))
+	r.mux.HandleFunc("/api/export", r.withMiddleware(r.configHandler.HandleExportResults))
 	
 	// 解锁检测相关路由
 	r.mux.HandleFunc("/api/unlock/platforms", r.withMiddleware(r.configHandler.HandleGetUnlockPlatforms))
+	
+	// 订阅相关路由，{profile} 可带 .yaml 后缀
+	r.mux.HandleFunc("/sub/{profile}", r.withMiddleware(r.subHandler.HandleSubscription))
 }
 
 // withMiddleware 应用中间件
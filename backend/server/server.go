@@ -10,27 +10,42 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/zhsama/clash-speedtest/config"
 	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/zhsama/clash-speedtest/scheduler"
+	"github.com/zhsama/clash-speedtest/tasks"
 	"github.com/zhsama/clash-speedtest/websocket"
 )
 
 // Server 服务器结构
 type Server struct {
-	httpServer *http.Server
-	wsHub      *websocket.Hub
-	router     *Router
-	port       int
+	httpServer  *http.Server
+	wsHub       *websocket.Hub
+	taskManager *tasks.Manager
+	scheduler   *scheduler.Scheduler
+	router      *Router
+	port        int
+	
+	schedulerEnabled bool
 }
 
 // NewServer 创建新的服务器
-func NewServer(port int) *Server {
+func NewServer(appConfig *config.Config) *Server {
+	port := appConfig.Server.Port
 	wsHub := websocket.NewHub()
-	router := NewRouter(wsHub)
+	taskManager := tasks.NewManager(wsHub, newTaskStore(appConfig.Storage))
+	taskManager.SetRetention(time.Duration(appConfig.Storage.RetentionDays) * 24 * time.Hour)
+	jobScheduler := scheduler.New(taskManager, appConfig.Scheduler.JobsFile)
+	router := NewRouter(wsHub, taskManager, jobScheduler, appConfig)
 	
 	server := &Server{
-		wsHub:  wsHub,
-		router: router,
-		port:   port,
+		wsHub:       wsHub,
+		taskManager: taskManager,
+		scheduler:   jobScheduler,
+		router:      router,
+		port:        port,
+		
+		schedulerEnabled: appConfig.Scheduler.Enabled,
 	}
 	
 	// 设置路由
@@ -48,11 +63,35 @@ func NewServer(port int) *Server {
 	return server
 }
 
+// newTaskStore 根据配置创建任务存储，未启用或创建失败时返回 nil
+func newTaskStore(storageConfig config.StorageConfig) tasks.Store {
+	if !storageConfig.Enabled {
+		return nil
+	}
+	
+	store, err := tasks.NewFileStore(storageConfig.Dir)
+	if err != nil {
+		logger.LogError("Failed to create task store, tasks will not be persisted", err,
+			slog.String("dir", storageConfig.Dir))
+		return nil
+	}
+	
+	return store
+}
+
 // Start 启动服务器
 func (s *Server) Start() error {
 	// 启动 WebSocket Hub
 	go s.wsHub.Run()
 	
+	// 启动任务管理器
+	s.taskManager.Start()
+	
+	// 启动定时任务调度器
+	if s.schedulerEnabled {
+		s.scheduler.Start()
+	}
+	
 	// 启动 HTTP 服务器
 	go func() {
 		logger.Logger.Info("Starting HTTP server",
@@ -83,6 +122,10 @@ func (s *Server) Shutdown() error {
 	
 	// WebSocket Hub 会在连接关闭时自动清理
 	
+	// 停止调度并取消运行中的任务
+	s.scheduler.Stop()
+	s.taskManager.Stop()
+	
 	// 关闭 HTTP 服务器
 	if err := s.httpServer.Shutdown(ctx); err != nil {
 		logger.Logger.Error("Failed to shutdown server gracefully", 
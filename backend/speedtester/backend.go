@@ -0,0 +1,155 @@
+package speedtester
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 带宽测试后端
+const (
+	BackendCloudflare     = "cloudflare"      // Cloudflare 测速协议：GET /__down?bytes=N，POST /__up
+	BackendDownloadServer = "download-server" // 本项目的 download-server，协议与 Cloudflare 相同，另可下载种子数据用于校验
+	BackendFile           = "file"            // 任意支持 Range 请求的大文件地址，仅测试下载
+	BackendLibreSpeed     = "librespeed"      // LibreSpeed 后端的 garbage.php 与 empty.php
+)
+
+// BandwidthBackends 支持的带宽测试后端
+var BandwidthBackends = []string{BackendCloudflare, BackendDownloadServer, BackendFile, BackendLibreSpeed}
+
+// librespeedChunkSize LibreSpeed garbage.php 的 ckSize 参数以 1 MiB 为单位
+const librespeedChunkSize = 1024 * 1024
+
+// BandwidthBackend 构造测速服务器的下载与上传请求
+type BandwidthBackend interface {
+	Name() string
+	// Server 测速服务器地址，用于记录产生结果的服务器
+	Server() string
+	// DownloadRequest 构造下载 size 字节的请求，响应可能多于 size 字节，调用方只读取前 size 字节
+	DownloadRequest(ctx context.Context, size int) (*http.Request, error)
+	// UploadRequest 构造上传 body 的请求，body 共 size 字节
+	UploadRequest(ctx context.Context, body io.Reader, size int) (*http.Request, error)
+	// SupportsUpload 后端是否支持上传测试
+	SupportsUpload() bool
+	// LatencyURL 延迟测试默认请求的地址
+	LatencyURL() string
+}
+
+// NewBandwidthBackend 按名称创建带宽测试后端，名称为空时使用 Cloudflare 协议。
+// serverURL 对 file 后端为文件地址，对其他后端为服务器根地址（LibreSpeed 为 garbage.php 所在目录）
+func NewBandwidthBackend(name, serverURL string) (BandwidthBackend, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server URL: %s", serverURL)
+	}
+	base := strings.TrimSuffix(serverURL, "/")
+
+	switch name {
+	case "", BackendCloudflare:
+		return &cloudflareBackend{name: BackendCloudflare, base: base}, nil
+	case BackendDownloadServer:
+		return &downloadServerBackend{cloudflareBackend{name: BackendDownloadServer, base: base}}, nil
+	case BackendFile:
+		return &fileBackend{url: serverURL}, nil
+	case BackendLibreSpeed:
+		return &librespeedBackend{base: base}, nil
+	default:
+		return nil, fmt.Errorf("unknown bandwidth backend %q", name)
+	}
+}
+
+// downloadStatusOK 判断下载响应的状态码，Range 请求返回 206
+func downloadStatusOK(status int) bool {
+	return status == http.StatusOK || status == http.StatusPartialContent
+}
+
+// newUploadRequest 构造以二进制流上传 body 的 POST 请求
+func newUploadRequest(ctx context.Context, target string, body io.Reader, size int) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(size)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	return req, nil
+}
+
+// cloudflareBackend 实现 speed.cloudflare.com 的测速协议，download-server 使用相同的接口
+type cloudflareBackend struct {
+	name string
+	base string
+}
+
+func (b *cloudflareBackend) Name() string         { return b.name }
+func (b *cloudflareBackend) Server() string       { return b.base }
+func (b *cloudflareBackend) SupportsUpload() bool { return true }
+func (b *cloudflareBackend) LatencyURL() string   { return b.base + "/__down?bytes=0" }
+
+func (b *cloudflareBackend) DownloadRequest(ctx context.Context, size int) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/__down?bytes=%d", b.base, size), nil)
+}
+
+func (b *cloudflareBackend) UploadRequest(ctx context.Context, body io.Reader, size int) (*http.Request, error) {
+	return newUploadRequest(ctx, b.base+"/__up", body, size)
+}
+
+// downloadServerBackend 在 Cloudflare 协议之外支持 seed 参数，下载内容由种子生成，可在客户端校验
+type downloadServerBackend struct {
+	cloudflareBackend
+}
+
+func (b *downloadServerBackend) SeededDownloadRequest(ctx context.Context, size int, seed uint64) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/__down?bytes=%d&seed=%d", b.base, size, seed), nil)
+}
+
+// fileBackend 通过 Range 请求下载任意大文件的前 size 字节；服务器不支持 Range 时只读取前 size 字节。
+// 延迟测试默认请求同一文件并只等待响应头，文件较大时建议另行设置 LatencyURL
+type fileBackend struct {
+	url string
+}
+
+func (b *fileBackend) Name() string         { return BackendFile }
+func (b *fileBackend) Server() string       { return b.url }
+func (b *fileBackend) SupportsUpload() bool { return false }
+func (b *fileBackend) LatencyURL() string   { return b.url }
+
+func (b *fileBackend) DownloadRequest(ctx context.Context, size int) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", size-1))
+	return req, nil
+}
+
+func (b *fileBackend) UploadRequest(ctx context.Context, body io.Reader, size int) (*http.Request, error) {
+	return nil, fmt.Errorf("%s backend does not support upload", BackendFile)
+}
+
+// librespeedBackend 实现 LibreSpeed 后端接口：garbage.php 按 MiB 返回随机数据，empty.php 丢弃上传内容。
+// 请求附带随机参数避免被缓存，与 LibreSpeed 前端一致
+type librespeedBackend struct {
+	base string
+}
+
+func (b *librespeedBackend) Name() string         { return BackendLibreSpeed }
+func (b *librespeedBackend) Server() string       { return b.base }
+func (b *librespeedBackend) SupportsUpload() bool { return true }
+
+func (b *librespeedBackend) LatencyURL() string {
+	return b.base + "/empty.php"
+}
+
+func (b *librespeedBackend) DownloadRequest(ctx context.Context, size int) (*http.Request, error) {
+	chunks := max((size+librespeedChunkSize-1)/librespeedChunkSize, 1)
+	target := fmt.Sprintf("%s/garbage.php?r=%d&ckSize=%d", b.base, rand.Uint64(), chunks)
+	return http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+}
+
+func (b *librespeedBackend) UploadRequest(ctx context.Context, body io.Reader, size int) (*http.Request, error) {
+	return newUploadRequest(ctx, fmt.Sprintf("%s/empty.php?r=%d", b.base, rand.Uint64()), body, size)
+}
@@ -0,0 +1,153 @@
+package speedtester
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/metacubex/mihomo/constant"
+)
+
+// loadProbeInterval 负载期间延迟探测的间隔
+const loadProbeInterval = 200 * time.Millisecond
+
+// bufferbloatGrades 按负载下延迟增量划分的等级，与常见 bufferbloat 测试的分级一致
+var bufferbloatGrades = []struct {
+	grade    string
+	increase time.Duration // 增量低于该值时取该等级
+}{
+	{"A+", 5 * time.Millisecond},
+	{"A", 30 * time.Millisecond},
+	{"B", 60 * time.Millisecond},
+	{"C", 200 * time.Millisecond},
+	{"D", 400 * time.Millisecond},
+}
+
+// loadProbe 在带宽测试期间通过同一代理持续测量往返时间
+type loadProbe struct {
+	cancel  context.CancelFunc
+	done    chan struct{}
+	mutex   sync.Mutex
+	samples []time.Duration
+}
+
+// startLoadProbe 开始负载下的延迟探测，未开启 Bufferbloat 时返回 nil
+func (st *SpeedTester) startLoadProbe(proxy constant.Proxy) *loadProbe {
+	if !st.config.Bufferbloat {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	probe := &loadProbe{cancel: cancel, done: make(chan struct{})}
+	client := st.createClient(proxy, st.config.Timeout)
+	target := st.latencyURL()
+
+	// 在负载开始前预热连接，避免建连与代理握手计入负载下的延迟
+	if _, err := probeOnce(ctx, client, target); err != nil {
+		logger.Logger.Debug("Loaded latency probe warm-up failed",
+			slog.String("proxy_name", proxy.Name()),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	go func() {
+		defer close(probe.done)
+		defer client.CloseIdleConnections()
+
+		ticker := time.NewTicker(loadProbeInterval)
+		defer ticker.Stop()
+
+		for {
+			rtt, err := probeOnce(ctx, client, target)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				// 负载下超时同样反映排队延迟，按超时时间计入
+				logger.Logger.Debug("Loaded latency probe failed",
+					slog.String("proxy_name", proxy.Name()),
+					slog.String("error", err.Error()),
+				)
+				rtt = st.config.Timeout
+			}
+			probe.mutex.Lock()
+			probe.samples = append(probe.samples, rtt)
+			probe.mutex.Unlock()
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return probe
+}
+
+// stop 结束探测并返回往返时间的中位数，没有样本或未开启时返回 0
+func (p *loadProbe) stop() time.Duration {
+	if p == nil {
+		return 0
+	}
+	p.cancel()
+	<-p.done
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.samples) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(p.samples)
+	slices.Sort(sorted)
+	return percentile(sorted, 50)
+}
+
+// probeOnce 请求一次目标地址并返回往返时间
+func probeOnce(ctx context.Context, client *http.Client, target string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	return time.Since(start), nil
+}
+
+// gradeBufferbloat 以空闲延迟中位数为基准，按负载下延迟的最大增量评定等级
+func (st *SpeedTester) gradeBufferbloat(result *Result) {
+	if !st.config.Bufferbloat || (result.LoadedDownloadLatency == 0 && result.LoadedUploadLatency == 0) {
+		return
+	}
+
+	result.IdleLatency = result.LatencyMedian
+	if result.IdleLatency == 0 {
+		result.IdleLatency = result.Latency
+	}
+
+	increase := max(result.LoadedDownloadLatency, result.LoadedUploadLatency) - result.IdleLatency
+	result.BufferbloatGrade = "F"
+	for _, level := range bufferbloatGrades {
+		if increase < level.increase {
+			result.BufferbloatGrade = level.grade
+			break
+		}
+	}
+
+	logger.Logger.Debug("Bufferbloat measured",
+		slog.String("proxy_name", result.ProxyName),
+		slog.Int64("idle_latency_ms", result.IdleLatency.Milliseconds()),
+		slog.Int64("loaded_download_latency_ms", result.LoadedDownloadLatency.Milliseconds()),
+		slog.Int64("loaded_upload_latency_ms", result.LoadedUploadLatency.Milliseconds()),
+		slog.String("grade", result.BufferbloatGrade),
+	)
+}
@@ -41,6 +41,37 @@ func (r *ChunkedZeroReader) Read(p []byte) (n int, err error) {
 	return n, err
 }
 
+// ChunkedReader 与 ChunkedZeroReader 相同，按块读取任意数据源并在块之间等待，适用于VLESS
+type ChunkedReader struct {
+	reader       io.Reader
+	chunkSize    int
+	delayBetween time.Duration
+	lastRead     time.Time
+}
+
+func NewChunkedReader(reader io.Reader, chunkSize int, delayBetween time.Duration) *ChunkedReader {
+	return &ChunkedReader{
+		reader:       reader,
+		chunkSize:    chunkSize,
+		delayBetween: delayBetween,
+		lastRead:     time.Now(),
+	}
+}
+
+func (r *ChunkedReader) Read(p []byte) (n int, err error) {
+	if r.delayBetween > 0 && time.Since(r.lastRead) < r.delayBetween {
+		time.Sleep(r.delayBetween - time.Since(r.lastRead))
+	}
+
+	if r.chunkSize > 0 && len(p) > r.chunkSize {
+		p = p[:r.chunkSize]
+	}
+	n, err = r.reader.Read(p)
+
+	r.lastRead = time.Now()
+	return n, err
+}
+
 // BufferedReader 提供带缓冲的读取器，适用于VLESS
 type BufferedReader struct {
 	reader    io.Reader
@@ -0,0 +1,100 @@
+package speedtester
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/metacubex/mihomo/constant"
+)
+
+// Default dual-stack targets. The hosts are IP literals so each probe can only
+// succeed over its own address family.
+const (
+	DefaultIPv4Target = "http://1.1.1.1/cdn-cgi/trace"
+	DefaultIPv6Target = "http://[2606:4700:4700::1111]/cdn-cgi/trace"
+)
+
+// dualStackPings 每个协议族的探测次数
+const dualStackPings = 3
+
+// dualStackEnabled 判断是否需要探测 IPv4/IPv6 连通性（要求 IPv6 时自动探测）
+func (st *SpeedTester) dualStackEnabled() bool {
+	return st.config.DualStack || st.config.RequireIPv6
+}
+
+// probeDualStack 通过代理分别请求仅 IPv4 与仅 IPv6 可达的目标，记录各协议族的连通性与延迟
+func (st *SpeedTester) probeDualStack(proxy *CProxy, result *Result) {
+	if !st.dualStackEnabled() || result.PacketLoss >= 100 {
+		return
+	}
+
+	ipv4Target, ipv6Target := st.config.IPv4Target, st.config.IPv6Target
+	if ipv4Target == "" {
+		ipv4Target = DefaultIPv4Target
+	}
+	if ipv6Target == "" {
+		ipv6Target = DefaultIPv6Target
+	}
+
+	var wg sync.WaitGroup
+	var v4, v6 *latencyResult
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		v4 = st.pingTarget(proxy.Proxy, ipv4Target, dualStackPings)
+	}()
+	go func() {
+		defer wg.Done()
+		v6 = st.pingTarget(proxy.Proxy, ipv6Target, dualStackPings)
+	}()
+	wg.Wait()
+
+	result.IPv4OK = v4.packetLoss < 100
+	result.IPv4Latency = v4.avgLatency
+	result.IPv6OK = v6.packetLoss < 100
+	result.IPv6Latency = v6.avgLatency
+
+	if st.config.RequireIPv6 && !result.IPv6OK {
+		result.FailureStage = StageConnect
+		result.FailureReason = "IPv6 target unreachable through proxy"
+	}
+
+	logger.Logger.Debug("Dual-stack probe completed",
+		slog.String("proxy_name", result.ProxyName),
+		slog.Bool("ipv4_ok", result.IPv4OK),
+		slog.Int64("ipv4_latency_ms", result.IPv4Latency.Milliseconds()),
+		slog.Bool("ipv6_ok", result.IPv6OK),
+		slog.Int64("ipv6_latency_ms", result.IPv6Latency.Milliseconds()),
+	)
+}
+
+// pingTarget 通过代理多次请求目标地址，任何 HTTP 响应都视为可达
+func (st *SpeedTester) pingTarget(proxy constant.Proxy, target string, attempts int) *latencyResult {
+	client := st.createClient(proxy, st.config.Timeout)
+	defer client.CloseIdleConnections()
+
+	latencies := make([]time.Duration, 0, attempts)
+	failedPings := 0
+	for i := range attempts {
+		start := time.Now()
+		resp, err := client.Get(target)
+		if err != nil {
+			logger.Logger.Debug("Target ping failed",
+				slog.String("proxy_name", proxy.Name()),
+				slog.String("target", target),
+				slog.Int("attempt", i+1),
+				slog.String("error", err.Error()),
+			)
+			failedPings++
+			continue
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		latencies = append(latencies, time.Since(start))
+	}
+
+	return calculateLatencyStats(latencies, failedPings, attempts)
+}
@@ -0,0 +1,229 @@
+package speedtester
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/zhsama/clash-speedtest/utils/geo"
+)
+
+// Default IP echo endpoints. Each returns the caller's address as plain text;
+// the IPv6 ones only resolve to IPv6 hosts, so they fail on IPv4-only nodes.
+var (
+	DefaultEgressEndpoints = []string{
+		"https://api.ipify.org",
+		"https://ipv4.icanhazip.com",
+		"https://ifconfig.me/ip",
+	}
+	DefaultEgressEndpointsV6 = []string{
+		"https://api6.ipify.org",
+		"https://ipv6.icanhazip.com",
+	}
+)
+
+// entryResolveTimeout 解析入口域名的超时时间
+const entryResolveTimeout = 5 * time.Second
+
+// egressEnabled 判断是否需要探测出口 IP（重命名依赖出口 IP）
+func (st *SpeedTester) egressEnabled() bool {
+	return st.config.EgressProbe || st.config.RenameNodes
+}
+
+// probeEgress 解析入口地址，并通过代理请求 IP 回显服务获取出口 IPv4/IPv6，
+// 出口与入口不同时标记为中转
+func (st *SpeedTester) probeEgress(proxy *CProxy, result *Result) {
+	if !st.egressEnabled() || result.PacketLoss >= 100 {
+		return
+	}
+
+	entryIPs := resolveEntryIPs(result.ProxyIP)
+	if len(entryIPs) > 0 {
+		result.EntryIP = entryIPs[0].String()
+	}
+
+	client := st.createClient(proxy.Proxy, st.config.Timeout)
+
+	v4Endpoints, v6Endpoints := st.config.EgressEndpoints, st.config.EgressEndpointsV6
+	if len(v4Endpoints) == 0 && len(v6Endpoints) == 0 {
+		v4Endpoints, v6Endpoints = DefaultEgressEndpoints, DefaultEgressEndpointsV6
+	}
+
+	// IPv4 与 IPv6 并行探测，仅支持单栈的节点只会在另一侧超时一次
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for _, endpoints := range [][]string{v4Endpoints, v6Endpoints} {
+		if len(endpoints) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(endpoints []string) {
+			defer wg.Done()
+			ip, err := probeEchoEndpoints(client, endpoints)
+			if err != nil {
+				logger.Logger.Debug("Egress probe failed",
+					slog.String("proxy_name", result.ProxyName),
+					slog.String("error", err.Error()),
+				)
+				return
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if ip.To4() != nil {
+				result.EgressIPv4 = ip.String()
+			} else {
+				result.EgressIPv6 = ip.String()
+			}
+		}(endpoints)
+	}
+	wg.Wait()
+
+	result.Relayed = isRelayed(entryIPs, result.EgressIPv4, result.EgressIPv6)
+	st.locateEgress(result)
+
+	logger.Logger.Debug("Egress probe completed",
+		slog.String("proxy_name", result.ProxyName),
+		slog.String("entry_ip", result.EntryIP),
+		slog.String("egress_ipv4", result.EgressIPv4),
+		slog.String("egress_ipv6", result.EgressIPv6),
+		slog.Bool("relayed", result.Relayed),
+		slog.Uint64("asn", uint64(result.ASN)),
+		slog.String("asn_type", result.ASNType),
+	)
+}
+
+// locateEgress 查询出口 IP 的地理位置与自治系统，并按 ASN 分类表标记住宅/机房/移动/教育网
+func (st *SpeedTester) locateEgress(result *Result) {
+	exitIP := result.primaryEgressIP()
+	if exitIP == "" {
+		return
+	}
+
+	location, err := st.locations.GetLocationByIP(exitIP)
+	if err != nil {
+		logger.Logger.Debug("Failed to locate exit IP",
+			slog.String("proxy_name", result.ProxyName),
+			slog.String("exit_ip", exitIP),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	result.ExitLocation = location
+	result.ASN, result.ASOrg = location.ASInfo()
+	if result.ASN != 0 {
+		result.ASNType = geo.ClassifyASN(result.ASN, result.ASOrg)
+	}
+}
+
+// resolveEntryIPs 解析配置中的 server 地址，IPv4 地址排在前面
+func resolveEntryIPs(server string) []net.IP {
+	if server == "" {
+		return nil
+	}
+	if ip := net.ParseIP(server); ip != nil {
+		return []net.IP{ip}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), entryResolveTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, server)
+	if err != nil {
+		logger.Logger.Debug("Failed to resolve entry address",
+			slog.String("server", server),
+			slog.String("error", err.Error()),
+		)
+		return nil
+	}
+
+	var v4, v6 []net.IP
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			v4 = append(v4, addr.IP)
+		} else {
+			v6 = append(v6, addr.IP)
+		}
+	}
+	return append(v4, v6...)
+}
+
+// isRelayed 判断出口 IP 是否不属于入口地址解析出的同协议族 IP。
+// 入口没有对应协议族的地址时无法比较，例如 IPv4 入口的双栈节点的 IPv6 出口
+func isRelayed(entryIPs []net.IP, egressIPs ...string) bool {
+	for _, egress := range egressIPs {
+		ip := net.ParseIP(egress)
+		if ip == nil {
+			continue
+		}
+
+		comparable, matched := false, false
+		for _, entry := range entryIPs {
+			if (entry.To4() != nil) != (ip.To4() != nil) {
+				continue
+			}
+			comparable = true
+			if entry.Equal(ip) {
+				matched = true
+				break
+			}
+		}
+		if comparable && !matched {
+			return true
+		}
+	}
+	return false
+}
+
+// primaryEgressIP 返回首选的出口 IP，优先 IPv4
+func (r *Result) primaryEgressIP() string {
+	if r.EgressIPv4 != "" {
+		return r.EgressIPv4
+	}
+	return r.EgressIPv6
+}
+
+// probeEchoEndpoints 依次请求回显服务，返回第一个有效的 IP
+func probeEchoEndpoints(client *http.Client, endpoints []string) (net.IP, error) {
+	var lastErr error
+	for _, endpoint := range endpoints {
+		ip, err := requestEchoIP(client, endpoint)
+		if err == nil {
+			return ip, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// requestEchoIP 请求单个 IP 回显服务
+func requestEchoIP(client *http.Client, endpoint string) (net.IP, error) {
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", endpoint, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return nil, fmt.Errorf("%s returned invalid IP %q", endpoint, strings.TrimSpace(string(body)))
+	}
+	return ip, nil
+}
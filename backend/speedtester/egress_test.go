@@ -0,0 +1,98 @@
+package speedtester
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer 模拟 IP 回显服务，以纯文本返回 body
+func echoServer(t *testing.T, status int, body string, delay time.Duration) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestProbeEchoEndpoints(t *testing.T) {
+	ipv4 := echoServer(t, http.StatusOK, "203.0.113.7\n", 0)
+	ipv6 := echoServer(t, http.StatusOK, "2001:db8::7", 0)
+	notIP := echoServer(t, http.StatusOK, "<html>blocked</html>", 0)
+	failing := echoServer(t, http.StatusServiceUnavailable, "", 0)
+	slow := echoServer(t, http.StatusOK, "203.0.113.8", time.Second)
+
+	tests := []struct {
+		name      string
+		endpoints []string
+		want      string
+		wantErr   string
+	}{
+		{"IPv4", []string{ipv4}, "203.0.113.7", ""},
+		{"IPv6", []string{ipv6}, "2001:db8::7", ""},
+		{"not an IP", []string{notIP}, "", "invalid IP"},
+		{"bad status", []string{failing}, "", "status 503"},
+		{"timeout", []string{slow}, "", "Client.Timeout"},
+		{"falls back to next endpoint", []string{notIP, slow, ipv4}, "203.0.113.7", ""},
+		{"reports last error", []string{failing, notIP}, "", "invalid IP"},
+	}
+
+	client := &http.Client{Timeout: 100 * time.Millisecond}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, err := probeEchoEndpoints(client, tt.endpoints)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("probeEchoEndpoints() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("probeEchoEndpoints() error: %v", err)
+			}
+			if !ip.Equal(net.ParseIP(tt.want)) {
+				t.Errorf("probeEchoEndpoints() = %s, want %s", ip, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRelayed(t *testing.T) {
+	entry := []net.IP{net.ParseIP("198.51.100.1"), net.ParseIP("2001:db8::1")}
+
+	tests := []struct {
+		name     string
+		entryIPs []net.IP
+		egress   []string
+		want     bool
+	}{
+		{"same IPv4", entry, []string{"198.51.100.1", ""}, false},
+		{"same IPv6", entry, []string{"", "2001:db8::1"}, false},
+		{"different IPv4", entry, []string{"203.0.113.7", ""}, true},
+		{"different IPv6", entry, []string{"198.51.100.1", "2001:db8::7"}, true},
+		// IPv4 入口无法与 IPv6 出口比较
+		{"no comparable family", entry[:1], []string{"", "2001:db8::7"}, false},
+		{"unresolved entry", nil, []string{"203.0.113.7", ""}, false},
+		{"no egress", entry, []string{"", ""}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRelayed(tt.entryIPs, tt.egress...); got != tt.want {
+				t.Errorf("isRelayed(%v, %v) = %v, want %v", tt.entryIPs, tt.egress, got, tt.want)
+			}
+		})
+	}
+}
@@ -22,6 +22,7 @@ const (
 	StageConnect    = "connect"
 	StageHandshake  = "handshake"
 	StageTransfer   = "transfer"
+	StageIntegrity  = "integrity"
 )
 
 // Error code constants
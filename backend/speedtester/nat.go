@@ -0,0 +1,70 @@
+package speedtester
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/zhsama/clash-speedtest/utils/stun"
+	"github.com/metacubex/mihomo/constant"
+)
+
+// natEnabled 判断是否需要进行 NAT 类型检测
+func (st *SpeedTester) natEnabled() bool {
+	return st.config.STUNServer != ""
+}
+
+// testNAT 通过代理的 UDP 会话向 STUN 服务发起 RFC 5780 行为探测，记录 NAT 映射与过滤类型
+func (st *SpeedTester) testNAT(proxy *CProxy, result *Result) {
+	if !st.natEnabled() || result.PacketLoss >= 100 || !proxy.SupportUDP() {
+		return
+	}
+
+	nat, err := st.discoverNAT(proxy.Proxy)
+	if err != nil {
+		result.NATType = stun.NATUnknown
+		logger.Logger.Debug("NAT detection failed",
+			slog.String("proxy_name", result.ProxyName),
+			slog.String("stun_server", st.config.STUNServer),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	result.NATType = nat.NATType
+	result.NATMapping = nat.Mapping
+	result.NATFiltering = nat.Filtering
+
+	logger.Logger.Debug("NAT detection completed",
+		slog.String("proxy_name", result.ProxyName),
+		slog.String("nat_type", result.NATType),
+		slog.String("nat_mapping", result.NATMapping),
+		slog.String("nat_filtering", result.NATFiltering),
+		slog.String("mapped_address", nat.MappedAddress.String()),
+	)
+}
+
+// discoverNAT 建立 UDP 会话并在 Timeout 内运行 STUN 探测，最多发出 5 个请求，每个请求重传一次
+func (st *SpeedTester) discoverNAT(proxy constant.Proxy) (*stun.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), st.config.Timeout)
+	defer cancel()
+
+	server, err := resolveUDPTarget(ctx, st.config.STUNServer)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := proxy.ListenPacketContext(ctx, &constant.Metadata{
+		NetWork: constant.UDP,
+		DstIP:   server.Addr(),
+		DstPort: server.Port(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open UDP session: %w", err)
+	}
+	defer conn.Close()
+
+	// Timeout 同时限制建立会话与全部探测；过滤受限时会有 4 次等待超时，每次等待取 Timeout 的五分之一
+	return stun.Discover(ctx, conn, server, min(stun.DefaultRequestTimeout, st.config.Timeout/5))
+}
@@ -0,0 +1,277 @@
+package speedtester
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// readPayload 以 chunk 字节为单位读取全部载荷
+func readPayload(t *testing.T, r io.Reader, chunk int) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	buf := make([]byte, chunk)
+	for {
+		n, err := r.Read(buf)
+		out.Write(buf[:n])
+		if err == io.EOF {
+			return out.Bytes()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPayloadReader(t *testing.T) {
+	const size = 100_003
+
+	reference := readPayload(t, NewPayloadReader(42, size), 32*1024)
+	if len(reference) != size {
+		t.Fatalf("read %d bytes, want %d", len(reference), size)
+	}
+
+	// 数据只由种子决定，与读取时的分块无关
+	for _, chunk := range []int{1, 7, 4096, size, 2 * size} {
+		if got := readPayload(t, NewPayloadReader(42, size), chunk); !bytes.Equal(got, reference) {
+			t.Errorf("payload read in %d-byte chunks differs", chunk)
+		}
+	}
+
+	if other := readPayload(t, NewPayloadReader(43, size), 32*1024); bytes.Equal(other, reference) {
+		t.Error("different seeds produced the same payload")
+	}
+
+	reader := NewPayloadReader(42, size)
+	io.Copy(io.Discard, reader)
+	if reader.WrittenBytes() != size {
+		t.Errorf("WrittenBytes() = %d, want %d", reader.WrittenBytes(), size)
+	}
+	hash := NewPayloadHash()
+	hash.Write(reference)
+	if reader.Checksum() != PayloadChecksum(hash) {
+		t.Errorf("Checksum() = %s, want %s", reader.Checksum(), PayloadChecksum(hash))
+	}
+}
+
+func TestDownloadCheckVerify(t *testing.T) {
+	const seed, size = 7, 64 * 1024
+	payload := readPayload(t, NewPayloadReader(seed, size), 32*1024)
+
+	corrupted := bytes.Clone(payload)
+	corrupted[size/2] ^= 0xff
+
+	tests := []struct {
+		name     string
+		received []byte
+		wantErr  error
+	}{
+		{"intact", payload, nil},
+		{"truncated", payload[:size-1], ErrPayloadTruncated},
+		{"empty", nil, ErrPayloadTruncated},
+		{"corrupted", corrupted, ErrPayloadCorrupted},
+		{"zeros", make([]byte, size), ErrPayloadCorrupted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := &downloadCheck{seed: seed, size: size, hash: NewPayloadHash()}
+			n, _ := io.Copy(io.Discard, check.wrap(bytes.NewReader(tt.received)))
+			if err := check.verify(n); !errors.Is(err, tt.wantErr) {
+				t.Errorf("verify() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	var nilCheck *downloadCheck
+	if err := nilCheck.verify(0); err != nil {
+		t.Errorf("nil check verify() = %v, want nil", err)
+	}
+}
+
+func TestVerifyUploadEcho(t *testing.T) {
+	const size = 4096
+	payload := NewPayloadReader(9, size)
+	io.Copy(io.Discard, payload)
+
+	echo := func(checksum string, received int) http.Header {
+		header := http.Header{}
+		if checksum != "" {
+			header.Set(PayloadChecksumHeader, checksum)
+		}
+		if received >= 0 {
+			header.Set(PayloadBytesHeader, strconv.Itoa(received))
+		}
+		return header
+	}
+
+	tests := []struct {
+		name         string
+		header       http.Header
+		payload      *PayloadReader
+		wantVerified bool
+		wantErr      error
+	}{
+		{"not verifying", echo(payload.Checksum(), size), nil, false, nil},
+		{"server without echo", http.Header{}, payload, false, nil},
+		{"intact", echo(payload.Checksum(), size), payload, true, nil},
+		{"intact without byte count", echo(payload.Checksum(), -1), payload, true, nil},
+		{"truncated", echo("00000000", size-1), payload, true, ErrPayloadTruncated},
+		{"corrupted", echo("00000000", size), payload, true, ErrPayloadCorrupted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verified, err := verifyUploadEcho(tt.header, tt.payload)
+			if verified != tt.wantVerified || !errors.Is(err, tt.wantErr) {
+				t.Errorf("verifyUploadEcho() = (%v, %v), want (%v, %v)", verified, err, tt.wantVerified, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyIntegrity(t *testing.T) {
+	corrupted := &integrityCheck{verified: 1}
+	corrupted.record(ErrPayloadCorrupted)
+
+	tests := []struct {
+		name         string
+		check        *integrityCheck
+		stage        string
+		wantOK       bool
+		wantVerified bool
+		wantStage    string
+	}{
+		{"not verifying", nil, "", true, false, ""},
+		{"nothing verified", &integrityCheck{}, "", true, false, ""},
+		{"verified", &integrityCheck{verified: 2}, "", true, true, ""},
+		{"failed", corrupted, "", false, false, StageIntegrity},
+		{"keeps earlier failure stage", corrupted, StageConnect, false, false, StageConnect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &Result{FailureStage: tt.stage}
+			ok := applyIntegrity(result, tt.check, "download")
+			if ok != tt.wantOK || result.PayloadVerified != tt.wantVerified || result.FailureStage != tt.wantStage {
+				t.Errorf("applyIntegrity() = %v, verified %v, stage %q; want %v, verified %v, stage %q",
+					ok, result.PayloadVerified, result.FailureStage, tt.wantOK, tt.wantVerified, tt.wantStage)
+			}
+			if !tt.wantOK && result.IntegrityError == "" {
+				t.Error("IntegrityError not set")
+			}
+		})
+	}
+}
+
+// payloadServer 模拟 download-server 的种子下载与上传回显，tamper 可修改下载内容
+func payloadServer(tamper func([]byte) []byte) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/__down", func(w http.ResponseWriter, r *http.Request) {
+		size, _ := strconv.Atoi(r.URL.Query().Get("bytes"))
+		seed, _ := strconv.ParseUint(r.URL.Query().Get("seed"), 10, 64)
+		data, _ := io.ReadAll(NewPayloadReader(seed, size))
+		if tamper != nil {
+			data = tamper(data)
+		}
+		w.Write(data)
+	})
+	mux.HandleFunc("/__up", func(w http.ResponseWriter, r *http.Request) {
+		hash := NewPayloadHash()
+		received, _ := io.Copy(hash, r.Body)
+		w.Header().Set(PayloadChecksumHeader, PayloadChecksum(hash))
+		w.Header().Set(PayloadBytesHeader, strconv.FormatInt(received, 10))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestSeededDownloadEndToEnd(t *testing.T) {
+	const size = 256 * 1024
+
+	tests := []struct {
+		name    string
+		tamper  func([]byte) []byte
+		wantErr error
+	}{
+		{"intact", nil, nil},
+		{"corrupted", func(b []byte) []byte { b[0] ^= 1; return b }, ErrPayloadCorrupted},
+		{"truncated", func(b []byte) []byte { return b[:len(b)/2] }, ErrPayloadTruncated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := payloadServer(tt.tamper)
+			defer server.Close()
+
+			backend, err := NewBandwidthBackend(BackendDownloadServer, server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			st := &SpeedTester{config: &Config{VerifyPayload: true}}
+			req, check, err := st.downloadRequest(context.Background(), backend, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if check == nil {
+				t.Fatal("download-server backend returned no check with VerifyPayload")
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			n, _ := io.Copy(io.Discard, check.wrap(io.LimitReader(resp.Body, size)))
+
+			if err := check.verify(n); !errors.Is(err, tt.wantErr) {
+				t.Errorf("verify() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnseededBackendsSkipVerification(t *testing.T) {
+	st := &SpeedTester{config: &Config{VerifyPayload: true}}
+	for _, name := range []string{BackendCloudflare, BackendLibreSpeed, BackendFile} {
+		backend, err := NewBandwidthBackend(name, "http://127.0.0.1:1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, check, err := st.downloadRequest(context.Background(), backend, 1024); err != nil || check != nil {
+			t.Errorf("%s: downloadRequest() check = %v, err = %v; want no check", name, check, err)
+		}
+	}
+}
+
+func TestUploadEchoEndToEnd(t *testing.T) {
+	const size = 128 * 1024
+
+	server := payloadServer(nil)
+	defer server.Close()
+
+	backend, err := NewBandwidthBackend(BackendDownloadServer, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &SpeedTester{config: &Config{VerifyPayload: true}}
+	payload := st.uploadPayload(size)
+
+	req, err := backend.UploadRequest(context.Background(), payload, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if verified, err := verifyUploadEcho(resp.Header, payload); !verified || err != nil {
+		t.Errorf("verifyUploadEcho() = (%v, %v), want (true, nil)", verified, err)
+	}
+}
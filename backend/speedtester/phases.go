@@ -0,0 +1,202 @@
+package speedtester
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/metacubex/mihomo/component/dialer"
+	"github.com/metacubex/mihomo/constant"
+)
+
+// phaseTimings 单次请求的各阶段耗时，未发生的阶段为 0（如复用连接时没有建连与 TLS 握手）
+type phaseTimings struct {
+	dial           time.Duration // 经代理建立到目标的连接，含代理协议握手
+	proxyConnect   time.Duration // 到代理服务器的 TCP 连接
+	proxyHandshake time.Duration // 代理协议握手（建连总耗时减去 TCP 连接）
+	tls            time.Duration // 与目标的 TLS 握手
+	write          time.Duration // 写入请求
+	ttfb           time.Duration // 请求写入完成到收到响应首字节
+}
+
+// phaseTraceKey 在请求 context 中传递 phaseTrace，供 createClient 的拨号函数记录建连耗时
+type phaseTraceKey struct{}
+
+// phaseTrace 通过 httptrace 记录一次请求的阶段时间点，回调可能来自不同 goroutine
+type phaseTrace struct {
+	mutex        sync.Mutex
+	gotConn      time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	dial         time.Duration
+	proxyConnect time.Duration
+}
+
+// context 返回挂载了追踪回调的 context
+func (t *phaseTrace) context(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, phaseTraceKey{}, t)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) {
+			t.mark(&t.gotConn)
+		},
+		TLSHandshakeStart: func() {
+			t.mark(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mark(&t.tlsDone)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mark(&t.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			t.mark(&t.firstByte)
+		},
+	})
+}
+
+// mark 记录当前时间
+func (t *phaseTrace) mark(at *time.Time) {
+	t.mutex.Lock()
+	*at = time.Now()
+	t.mutex.Unlock()
+}
+
+// recordDial 记录经代理建连的总耗时与其中到代理服务器的 TCP 连接耗时
+func (t *phaseTrace) recordDial(dial, proxyConnect time.Duration) {
+	t.mutex.Lock()
+	t.dial = dial
+	t.proxyConnect = proxyConnect
+	t.mutex.Unlock()
+}
+
+// timings 计算各阶段耗时
+func (t *phaseTrace) timings() phaseTimings {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	timings := phaseTimings{
+		dial:         t.dial,
+		proxyConnect: t.proxyConnect,
+		tls:          between(t.tlsStart, t.tlsDone),
+		write:        between(t.gotConn, t.wroteRequest),
+		ttfb:         between(t.wroteRequest, t.firstByte),
+	}
+	if t.proxyConnect > 0 && t.dial > t.proxyConnect {
+		timings.proxyHandshake = t.dial - t.proxyConnect
+	}
+	return timings
+}
+
+// between 返回两个时间点的间隔，任一时间点缺失时为 0
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// tracedGet 发送带阶段追踪的 GET 请求
+func tracedGet(client *http.Client, url string, trace *phaseTrace) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(trace.context(context.Background()), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// averagePhases 分别对各阶段求平均值，只统计该阶段实际发生的请求
+func averagePhases(samples []phaseTimings) phaseTimings {
+	average := func(phase func(phaseTimings) time.Duration) time.Duration {
+		var total time.Duration
+		count := 0
+		for _, sample := range samples {
+			if d := phase(sample); d > 0 {
+				total += d
+				count++
+			}
+		}
+		if count == 0 {
+			return 0
+		}
+		return total / time.Duration(count)
+	}
+
+	return phaseTimings{
+		dial:           average(func(p phaseTimings) time.Duration { return p.dial }),
+		proxyConnect:   average(func(p phaseTimings) time.Duration { return p.proxyConnect }),
+		proxyHandshake: average(func(p phaseTimings) time.Duration { return p.proxyHandshake }),
+		tls:            average(func(p phaseTimings) time.Duration { return p.tls }),
+		write:          average(func(p phaseTimings) time.Duration { return p.write }),
+		ttfb:           average(func(p phaseTimings) time.Duration { return p.ttfb }),
+	}
+}
+
+// dialProxy 经代理建立连接。请求带有阶段追踪时记录建连耗时，
+// 并在 mihomo 允许替换拨号器的协议上单独记录到代理服务器的 TCP 连接耗时
+func dialProxy(ctx context.Context, proxy constant.Proxy, metadata *constant.Metadata) (net.Conn, error) {
+	trace, _ := ctx.Value(phaseTraceKey{}).(*phaseTrace)
+	if trace == nil {
+		return proxy.DialContext(ctx, metadata)
+	}
+
+	start := time.Now()
+	if !supportsTimingDialer(proxy) {
+		conn, err := proxy.DialContext(ctx, metadata)
+		if err != nil {
+			return nil, err
+		}
+		trace.recordDial(time.Since(start), 0)
+		return conn, nil
+	}
+
+	timing := &timingDialer{Dialer: dialer.NewDialer(dialOptions(proxy)...)}
+	conn, err := proxy.DialContextWithDialer(ctx, timing, metadata)
+	if err != nil {
+		return nil, err
+	}
+	trace.recordDial(time.Since(start), timing.connect)
+	return conn, nil
+}
+
+// supportsTimingDialer 判断 DialContextWithDialer 是否与 DialContext 走相同的连接路径。
+// gRPC 传输、Snell v2 连接池和基于 QUIC 的协议使用各自的连接，不能替换拨号器
+func supportsTimingDialer(proxy constant.Proxy) bool {
+	switch proxy.Type() {
+	case constant.Http, constant.Socks5, constant.Shadowsocks, constant.ShadowsocksR:
+		return true
+	case constant.Vmess, constant.Vless, constant.Trojan:
+		cproxy, ok := proxy.(*CProxy)
+		return ok && cproxy.Config["network"] != "grpc"
+	}
+	return false
+}
+
+// dialOptions 返回适配器自身的拨号选项（出口网卡、路由标记等）
+func dialOptions(proxy constant.Proxy) []dialer.Option {
+	if adapter, ok := proxy.Adapter().(interface{ DialOptions() []dialer.Option }); ok {
+		return adapter.DialOptions()
+	}
+	return nil
+}
+
+// timingDialer 记录到代理服务器的 TCP 连接耗时
+type timingDialer struct {
+	dialer.Dialer
+	connect time.Duration
+}
+
+// DialContext implements constant.Dialer
+func (d *timingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	start := time.Now()
+	conn, err := d.Dialer.DialContext(ctx, network, address)
+	if err == nil {
+		d.connect = time.Since(start)
+	}
+	return conn, err
+}
@@ -0,0 +1,139 @@
+package speedtester
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/zhsama/clash-speedtest/logger"
+)
+
+// Pipeline phase constants
+const (
+	PhaseScreening = "screening"
+	PhaseBandwidth = "bandwidth"
+)
+
+// PhaseProgress 流水线模式下的阶段进度
+type PhaseProgress struct {
+	Phase              string `json:"phase"`      // 本次事件所属阶段
+	ProxyName          string `json:"proxy_name"` // 刚完成该阶段的节点
+	ScreeningCompleted int    `json:"screening_completed"`
+	ScreeningTotal     int    `json:"screening_total"`
+	BandwidthCompleted int    `json:"bandwidth_completed"`
+	BandwidthTotal     int    `json:"bandwidth_total"`
+}
+
+// SetProgressHandler sets the handler invoked with phase progress in pipeline mode
+func (st *SpeedTester) SetProgressHandler(handler func(progress PhaseProgress)) {
+	st.progressHandler = handler
+}
+
+// testProxiesPipelined 两阶段测试：先并发筛选全部节点延迟，再仅对筛选出的节点执行解锁与带宽测试
+func (st *SpeedTester) testProxiesPipelined(ctx context.Context, proxies map[string]*CProxy, callback func(result *Result)) error {
+	logger.Logger.Info("Starting pipelined proxy test",
+		slog.Int("proxy_count", len(proxies)),
+		slog.Int("node_concurrent", st.config.NodeConcurrent),
+		slog.Int("top_n", st.config.TopN),
+	)
+
+	var mutex sync.Mutex
+	progress := PhaseProgress{ScreeningTotal: len(proxies)}
+	screened := make([]*Result, 0, len(proxies))
+
+	// 1. 延迟筛选
+	err := st.runWorkerPool(ctx, proxyNames(proxies), func(name string) {
+		result := newResult(name, proxies[name])
+		st.measureLatency(proxies[name], result)
+
+		mutex.Lock()
+		screened = append(screened, result)
+		progress.ScreeningCompleted++
+		progress.Phase = PhaseScreening
+		progress.ProxyName = name
+		st.emitProgress(progress)
+		mutex.Unlock()
+	})
+
+	survivors, rejected := st.selectSurvivors(screened)
+
+	// 未通过筛选的节点直接返回延迟结果
+	for _, result := range rejected {
+		callback(result)
+	}
+	if err != nil {
+		// 筛选被取消时，已通过筛选的节点同样只返回延迟结果
+		for _, result := range survivors {
+			result.FailureStage = PhaseBandwidth
+			result.FailureReason = "cancelled before bandwidth test"
+			callback(result)
+		}
+		return err
+	}
+
+	logger.Logger.Info("Latency screening completed",
+		slog.Int("screened", len(screened)),
+		slog.Int("survivors", len(survivors)),
+	)
+
+	// 2. 解锁与带宽测试
+	byName := make(map[string]*Result, len(survivors))
+	names := make([]string, 0, len(survivors))
+	for _, result := range survivors {
+		byName[result.ProxyName] = result
+		names = append(names, result.ProxyName)
+	}
+	progress.BandwidthTotal = len(survivors)
+
+	return st.runWorkerPool(ctx, names, func(name string) {
+		result := byName[name]
+		st.finishProxyTest(ctx, proxies[name], result)
+
+		mutex.Lock()
+		callback(result)
+		progress.BandwidthCompleted++
+		progress.Phase = PhaseBandwidth
+		progress.ProxyName = name
+		st.emitProgress(progress)
+		mutex.Unlock()
+	})
+}
+
+// selectSurvivors 按延迟排序，保留满足 MaxLatency 的节点，并在设置 TopN 时截取前 N 个
+func (st *SpeedTester) selectSurvivors(screened []*Result) (survivors, rejected []*Result) {
+	for _, result := range screened {
+		if st.passesLatency(result) {
+			survivors = append(survivors, result)
+			continue
+		}
+		if result.FailureStage == "" {
+			result.FailureStage = PhaseScreening
+			result.FailureReason = st.latencyFailureReason(result)
+		}
+		rejected = append(rejected, result)
+	}
+
+	sort.Slice(survivors, func(i, j int) bool {
+		return survivors[i].Latency < survivors[j].Latency
+	})
+
+	if st.config.TopN > 0 && len(survivors) > st.config.TopN {
+		for _, result := range survivors[st.config.TopN:] {
+			result.FailureStage = PhaseScreening
+			result.FailureReason = fmt.Sprintf("not in top %d by latency", st.config.TopN)
+			rejected = append(rejected, result)
+		}
+		survivors = survivors[:st.config.TopN]
+	}
+
+	return survivors, rejected
+}
+
+// emitProgress 调用阶段进度处理器（调用方需持有锁）
+func (st *SpeedTester) emitProgress(progress PhaseProgress) {
+	if st.progressHandler != nil {
+		st.progressHandler(progress)
+	}
+}
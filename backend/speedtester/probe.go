@@ -0,0 +1,237 @@
+package speedtester
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/metacubex/mihomo/common/utils"
+	"github.com/metacubex/mihomo/constant"
+)
+
+// DefaultProbeURL is the default target of latency probes, the same as mihomo's health check
+const DefaultProbeURL = "https://www.gstatic.com/generate_204"
+
+// 延迟探测方式
+const (
+	ProbeHTTP = "http" // HEAD 请求 generate_204，与 mihomo url-test 一致
+	ProbeTCP  = "tcp"  // 仅经代理建立 TCP 连接，最接近 ping 的探测
+	ProbeTLS  = "tls"  // 经代理完成 TLS 握手，不发送请求
+)
+
+// ProbeMethods 支持的延迟探测方式
+var ProbeMethods = []string{ProbeHTTP, ProbeTCP, ProbeTLS}
+
+// LatencyProber 通过代理测量一次延迟
+type LatencyProber interface {
+	Method() string // 探测方式
+	Target() string // 探测目标
+	Probe(ctx context.Context, proxy constant.Proxy) (time.Duration, error)
+}
+
+// ProbeResult 单种探测方式的延迟统计
+type ProbeResult struct {
+	Method     string        `json:"method"`
+	Target     string        `json:"target"`
+	Latency    time.Duration `json:"latency"`
+	Jitter     time.Duration `json:"jitter"`
+	PacketLoss float64       `json:"packet_loss"`
+	Error      string        `json:"error,omitempty"` // 最后一次失败的原因
+}
+
+// NewLatencyProbers 按配置创建延迟探测器，TCP 与 TLS 探测连接探测地址的主机
+func NewLatencyProbers(methods []string, probeURL, expectedStatus string) ([]LatencyProber, error) {
+	if len(methods) == 0 {
+		return nil, nil
+	}
+	if probeURL == "" {
+		probeURL = DefaultProbeURL
+	}
+
+	u, err := url.Parse(probeURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid probe URL: %s", probeURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	var expected utils.IntRanges[uint16]
+	if expectedStatus != "" {
+		if expected, err = utils.NewUnsignedRanges[uint16](expectedStatus); err != nil {
+			return nil, fmt.Errorf("invalid expected status %q: %w", expectedStatus, err)
+		}
+	}
+
+	probers := make([]LatencyProber, 0, len(methods))
+	for _, method := range methods {
+		switch method {
+		case ProbeHTTP:
+			probers = append(probers, &httpProber{url: probeURL, expected: expected})
+		case ProbeTCP:
+			probers = append(probers, &tcpProber{address: net.JoinHostPort(u.Hostname(), port)})
+		case ProbeTLS:
+			// 明文探测地址时连接同一主机的 443 端口
+			tlsPort := port
+			if u.Scheme == "http" {
+				tlsPort = "443"
+			}
+			probers = append(probers, &tlsProber{address: net.JoinHostPort(u.Hostname(), tlsPort), serverName: u.Hostname()})
+		default:
+			return nil, fmt.Errorf("unknown latency probe %q", method)
+		}
+	}
+	return probers, nil
+}
+
+// httpProber 调用 mihomo 自身的 URLTest，结果与 url-test 策略组显示的延迟一致（毫秒精度）
+type httpProber struct {
+	url      string
+	expected utils.IntRanges[uint16]
+}
+
+func (p *httpProber) Method() string { return ProbeHTTP }
+func (p *httpProber) Target() string { return p.url }
+
+func (p *httpProber) Probe(ctx context.Context, proxy constant.Proxy) (time.Duration, error) {
+	delay, err := proxy.URLTest(ctx, p.url, p.expected)
+	if err != nil {
+		return 0, err
+	}
+	// URLTest 不返回状态码不符的错误，只记录在该地址的存活状态中
+	if !proxy.AliveForTestUrl(p.url) {
+		return 0, fmt.Errorf("unexpected status from %s, expected %s", p.url, p.expected)
+	}
+	return time.Duration(delay) * time.Millisecond, nil
+}
+
+// tcpProber 测量经代理建立 TCP 连接的耗时。
+// 部分协议（如 VMess、Shadowsocks）延迟到首次写入才发送握手，此时只反映到代理服务器的连接
+type tcpProber struct {
+	address string
+}
+
+func (p *tcpProber) Method() string { return ProbeTCP }
+func (p *tcpProber) Target() string { return p.address }
+
+func (p *tcpProber) Probe(ctx context.Context, proxy constant.Proxy) (time.Duration, error) {
+	start := time.Now()
+	conn, err := proxy.DialContext(ctx, probeMetadata(p.address))
+	if err != nil {
+		return 0, err
+	}
+	elapsed := time.Since(start)
+	conn.Close()
+	return elapsed, nil
+}
+
+// tlsProber 测量经代理建连并完成与目标的 TLS 握手的总耗时
+type tlsProber struct {
+	address    string
+	serverName string
+}
+
+func (p *tlsProber) Method() string { return ProbeTLS }
+func (p *tlsProber) Target() string { return p.address }
+
+func (p *tlsProber) Probe(ctx context.Context, proxy constant.Proxy) (time.Duration, error) {
+	start := time.Now()
+	conn, err := proxy.DialContext(ctx, probeMetadata(p.address))
+	if err != nil {
+		return 0, err
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: p.serverName})
+	defer tlsConn.Close()
+
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// probeMetadata 将 host:port 转换为代理连接的目标
+func probeMetadata(address string) *constant.Metadata {
+	host, port, _ := net.SplitHostPort(address)
+	dstPort, _ := strconv.ParseUint(port, 10, 16)
+
+	metadata := &constant.Metadata{Host: host, DstPort: uint16(dstPort)}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		metadata.Host = ""
+		metadata.DstIP = ip
+	}
+	return metadata
+}
+
+// runProbes 依次使用各探测器测量延迟，每种方式的次数与间隔与主延迟测试相同
+func (st *SpeedTester) runProbes(proxy *CProxy, result *Result) {
+	if len(st.probers) == 0 {
+		return
+	}
+
+	attempts := st.pingCount(proxy)
+	interval := st.pingInterval()
+	result.Probes = make([]ProbeResult, 0, len(st.probers))
+
+	for _, prober := range st.probers {
+		latencies := make([]time.Duration, 0, attempts)
+		var lastError error
+		for range attempts {
+			time.Sleep(interval)
+
+			ctx, cancel := context.WithTimeout(context.Background(), st.config.Timeout)
+			latency, err := prober.Probe(ctx, proxy.Proxy)
+			cancel()
+			if err != nil {
+				lastError = err
+				continue
+			}
+			latencies = append(latencies, latency)
+		}
+
+		stats := calculateLatencyStats(latencies, attempts-len(latencies), attempts)
+		probe := ProbeResult{
+			Method:     prober.Method(),
+			Target:     prober.Target(),
+			Latency:    stats.avgLatency,
+			Jitter:     stats.jitter,
+			PacketLoss: stats.packetLoss,
+		}
+		if lastError != nil {
+			probe.Error = lastError.Error()
+			if errors.Is(lastError, context.DeadlineExceeded) {
+				probe.Error = "timeout"
+			}
+		}
+		result.Probes = append(result.Probes, probe)
+
+		logger.Logger.Debug("Latency probe completed",
+			slog.String("proxy_name", result.ProxyName),
+			slog.String("method", probe.Method),
+			slog.String("target", probe.Target),
+			slog.Int64("latency_ms", probe.Latency.Milliseconds()),
+			slog.Float64("packet_loss", probe.PacketLoss),
+		)
+	}
+}
+
+// ProbeLatency 返回指定探测方式的平均延迟，未运行或全部失败时 ok 为 false
+func (r *Result) ProbeLatency(method string) (latency time.Duration, ok bool) {
+	for _, probe := range r.Probes {
+		if probe.Method == method && probe.PacketLoss < 100 {
+			return probe.Latency, true
+		}
+	}
+	return 0, false
+}
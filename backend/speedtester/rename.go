@@ -0,0 +1,144 @@
+package speedtester
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/zhsama/clash-speedtest/utils/geo"
+)
+
+// DefaultRenameTemplate is the node name template used when RenameNodes is on,
+// producing names like "🇯🇵 JP Tokyo | 45ms | 120Mbps".
+//
+// Supported placeholders: {flag}, {code}, {country}, {region}, {city}, {isp},
+// {ip}, {name}, {latency}, {download}, {upload}. Empty values are dropped
+// together with their surrounding "|" separator.
+const DefaultRenameTemplate = "{flag} {code} {city} | {latency} | {download}"
+
+// nameRegistry 记录已占用的节点名称（所有原始名称与重命名后的名称），用于重名去重
+type nameRegistry struct {
+	names map[string]bool
+	mutex sync.Mutex
+}
+
+// newNameRegistry 创建节点名称登记表
+func newNameRegistry() *nameRegistry {
+	return &nameRegistry{names: make(map[string]bool)}
+}
+
+// reserve 登记测试开始时的原始节点名称，避免重命名后与未重命名的节点重名
+func (r *nameRegistry) reserve(names []string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, name := range names {
+		r.names[name] = true
+	}
+}
+
+// unique 返回未被占用的名称，重名时追加 " #2"、" #3" 等序号；与节点自身的原始名称相同时直接使用
+func (r *nameRegistry) unique(name, original string) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	candidate := name
+	for i := 2; candidate != original && r.names[candidate]; i++ {
+		candidate = fmt.Sprintf("%s #%d", name, i)
+	}
+	r.names[candidate] = true
+	return candidate
+}
+
+// renameResult 按出口 IP 的地理位置和模板重命名节点（需先执行 probeEgress）
+func (st *SpeedTester) renameResult(result *Result) {
+	if !st.config.RenameNodes {
+		return
+	}
+
+	exitIP := result.primaryEgressIP()
+	if exitIP == "" {
+		logger.Logger.Debug("No exit IP resolved, keeping original name",
+			slog.String("proxy_name", result.ProxyName),
+		)
+		return
+	}
+
+	location := result.ExitLocation
+	if location == nil {
+		logger.Logger.Debug("Failed to locate exit IP, keeping original name",
+			slog.String("proxy_name", result.ProxyName),
+			slog.String("exit_ip", exitIP),
+		)
+		return
+	}
+
+	name := formatNodeName(st.renameTemplate(), result, location)
+	if name == "" {
+		return
+	}
+	name = st.names.unique(name, result.ProxyName)
+
+	// 复制配置，避免修改加载时共享的代理配置
+	proxyConfig := make(map[string]any, len(result.ProxyConfig))
+	for k, v := range result.ProxyConfig {
+		proxyConfig[k] = v
+	}
+	proxyConfig["name"] = name
+
+	logger.Logger.Debug("Proxy renamed",
+		slog.String("proxy_name", result.ProxyName),
+		slog.String("new_name", name),
+		slog.String("exit_ip", exitIP),
+	)
+
+	result.OriginalName = result.ProxyName
+	result.ProxyName = name
+	result.ProxyConfig = proxyConfig
+}
+
+// renameTemplate 返回生效的重命名模板
+func (st *SpeedTester) renameTemplate() string {
+	if st.config.RenameTemplate == "" {
+		return DefaultRenameTemplate
+	}
+	return st.config.RenameTemplate
+}
+
+// formatNodeName 按模板生成节点名称，并去掉空值留下的多余空格和分隔符
+func formatNodeName(template string, result *Result, location *geo.GeoLocation) string {
+	var latency, download, upload string
+	if result.Latency > 0 {
+		latency = fmt.Sprintf("%dms", result.Latency.Milliseconds())
+	}
+	if result.DownloadSpeed > 0 {
+		download = fmt.Sprintf("%.0fMbps", result.DownloadSpeed*8/1e6)
+	}
+	if result.UploadSpeed > 0 {
+		upload = fmt.Sprintf("%.0fMbps", result.UploadSpeed*8/1e6)
+	}
+
+	name := strings.NewReplacer(
+		"{flag}", geo.GetFlagEmoji(location.CountryCode),
+		"{code}", location.CountryCode,
+		"{country}", location.Country,
+		"{region}", location.RegionName,
+		"{city}", location.City,
+		"{isp}", location.ISP,
+		"{ip}", result.primaryEgressIP(),
+		"{name}", result.ProxyName,
+		"{latency}", latency,
+		"{download}", download,
+		"{upload}", upload,
+	).Replace(template)
+
+	var segments []string
+	for _, segment := range strings.Split(name, "|") {
+		if segment = strings.Join(strings.Fields(segment), " "); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, " | ")
+}
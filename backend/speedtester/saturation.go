@@ -0,0 +1,99 @@
+package speedtester
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/zhsama/clash-speedtest/logger"
+)
+
+// 饱和测试参数
+const (
+	DefaultSaturationStage = 3 * time.Second // 每个阶段的默认时长
+	saturationKneeRatio    = 0.9             // 达到最佳总吞吐量该比例的最少连接数视为拐点
+	perStreamLimitRatio    = 1.5             // 多连接吞吐量达到单连接的该倍数时视为单连接限速
+)
+
+// SaturationStage 饱和测试中一个阶段的结果，速度单位为字节/秒
+type SaturationStage struct {
+	Streams      int       `json:"streams"`       // 并发连接数
+	Speed        float64   `json:"speed"`         // 所有连接的总吞吐量（墙钟时间，去除慢启动）
+	StreamSpeeds []float64 `json:"stream_speeds"` // 各连接的平均吞吐量
+}
+
+// saturationEnabled 判断是否需要进行饱和测试
+func (st *SpeedTester) saturationEnabled() bool {
+	return st.config.SaturationStreams > 0
+}
+
+// saturationSteps 返回各阶段的连接数：从 1 开始翻倍，最后一个阶段为 SaturationStreams
+func saturationSteps(maxStreams int) []int {
+	steps := []int{}
+	for streams := 1; streams < maxStreams; streams *= 2 {
+		steps = append(steps, streams)
+	}
+	return append(steps, maxStreams)
+}
+
+// testSaturation 逐步增加从 backend 并发下载的连接数，记录单连接与多连接的吞吐量以及继续增加连接不再提升的拐点。
+// 开启 VerifyPayload 时同样校验下载内容，数据损坏或截断时不报告饱和测试的结果
+func (st *SpeedTester) testSaturation(proxy *CProxy, result *Result, backend BandwidthBackend) {
+	if !st.saturationEnabled() || result.PacketLoss >= 100 {
+		return
+	}
+
+	stage := st.config.SaturationStage
+	if stage <= 0 {
+		stage = DefaultSaturationStage
+	}
+	chunkSize := max(st.config.DownloadSize/st.config.Concurrent, 1024*1024)
+	integrity := st.newIntegrityCheck()
+	download := st.timedDownload(backend, chunkSize, integrity)
+
+	stages := make([]SaturationStage, 0, len(saturationSteps(st.config.SaturationStreams)))
+	for _, streams := range saturationSteps(st.config.SaturationStreams) {
+		transfer := st.timedTransfer(proxy, streams, stage, download)
+		if transfer == nil {
+			logger.Logger.Debug("Saturation stage transferred no data",
+				slog.String("proxy_name", result.ProxyName),
+				slog.Int("streams", streams),
+			)
+			break
+		}
+
+		streamSpeeds := make([]float64, len(transfer.streams))
+		for i, bytes := range transfer.streams {
+			streamSpeeds[i] = float64(bytes) / transfer.duration.Seconds()
+		}
+		stages = append(stages, SaturationStage{
+			Streams:      streams,
+			Speed:        transfer.sustained,
+			StreamSpeeds: streamSpeeds,
+		})
+	}
+	if len(stages) == 0 || !applyIntegrity(result, integrity, "saturation") {
+		return
+	}
+
+	result.SaturationStages = stages
+	result.SingleStreamSpeed = stages[0].Speed
+	for _, s := range stages {
+		result.MultiStreamSpeed = max(result.MultiStreamSpeed, s.Speed)
+	}
+	for _, s := range stages {
+		if s.Speed >= result.MultiStreamSpeed*saturationKneeRatio {
+			result.SaturationKnee = s.Streams
+			break
+		}
+	}
+	result.PerStreamLimited = result.SingleStreamSpeed > 0 &&
+		result.MultiStreamSpeed >= result.SingleStreamSpeed*perStreamLimitRatio
+
+	logger.Logger.Debug("Saturation test completed",
+		slog.String("proxy_name", result.ProxyName),
+		slog.Float64("single_stream_mbps", result.SingleStreamSpeed/(1024*1024)),
+		slog.Float64("multi_stream_mbps", result.MultiStreamSpeed/(1024*1024)),
+		slog.Int("knee_streams", result.SaturationKnee),
+		slog.Bool("per_stream_limited", result.PerStreamLimited),
+	)
+}
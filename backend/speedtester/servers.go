@@ -0,0 +1,108 @@
+package speedtester
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/zhsama/clash-speedtest/logger"
+)
+
+// 多服务器带宽测试的选择方式
+const (
+	ServerSelectNearest = "nearest" // 只测试节点到其延迟最低的服务器
+	ServerSelectAll     = "all"     // 测试所有服务器，以下载最快的服务器的结果作为节点结果
+)
+
+// serverProbeCount 选择服务器时到每个服务器的延迟请求次数
+const serverProbeCount = 3
+
+// ServerResult 节点到一个带宽测试服务器的延迟与吞吐量，速度单位为字节/秒
+type ServerResult struct {
+	Server        string        `json:"server"`
+	Latency       time.Duration `json:"latency"`
+	PacketLoss    float64       `json:"packet_loss"`
+	DownloadSpeed float64       `json:"download_speed,omitempty"` // 未测试该服务器时为 0
+	UploadSpeed   float64       `json:"upload_speed,omitempty"`
+	Error         string        `json:"error,omitempty"` // 服务器配置无效而未测试的原因
+}
+
+// testBandwidth 选择带宽测试服务器并测速，返回产生节点结果的服务器。
+// 配置了多个服务器时先测量节点到各服务器的延迟，按 ServerSelection 测试最近的或全部服务器
+func (st *SpeedTester) testBandwidth(proxy *CProxy, result *Result, isVless bool, name string) BandwidthBackend {
+	if len(st.backends) == 1 && len(st.skippedServers) == 0 {
+		backend := st.backends[0]
+		result.BandwidthServer = backend.Server()
+		st.performSpeedTests(proxy, result, backend, isVless, name)
+		return backend
+	}
+
+	servers := st.probeServers(proxy)
+	// 测速结束后再写入结果，此时已填入各服务器的吞吐量
+	defer func() {
+		result.ServerResults = append(servers, st.skippedServers...)
+	}()
+
+	if st.config.ServerSelection == ServerSelectAll {
+		best := -1
+		var bestResult Result
+		for i, backend := range st.backends {
+			if servers[i].PacketLoss >= 100 {
+				continue
+			}
+			trial := *result
+			trial.BandwidthServer = backend.Server()
+			st.performSpeedTests(proxy, &trial, backend, isVless, name)
+			servers[i].DownloadSpeed = trial.DownloadSpeed
+			servers[i].UploadSpeed = trial.UploadSpeed
+			if best < 0 || trial.DownloadSpeed > bestResult.DownloadSpeed {
+				best, bestResult = i, trial
+			}
+		}
+		if best >= 0 {
+			*result = bestResult
+			return st.backends[best]
+		}
+	}
+
+	nearest := nearestServer(servers)
+	backend := st.backends[nearest]
+	result.BandwidthServer = backend.Server()
+	st.performSpeedTests(proxy, result, backend, isVless, name)
+	servers[nearest].DownloadSpeed = result.DownloadSpeed
+	servers[nearest].UploadSpeed = result.UploadSpeed
+
+	logger.Logger.Debug("Bandwidth server selected",
+		slog.String("proxy_name", name),
+		slog.String("server", backend.Server()),
+		slog.Int64("latency_ms", servers[nearest].Latency.Milliseconds()),
+	)
+	return backend
+}
+
+// probeServers 通过代理测量到各带宽测试服务器的延迟，下标与 st.backends 一致（不含配置无效而跳过的服务器）
+func (st *SpeedTester) probeServers(proxy *CProxy) []ServerResult {
+	servers := make([]ServerResult, len(st.backends))
+	for i, backend := range st.backends {
+		ping := st.pingTarget(proxy, backend.LatencyURL(), serverProbeCount)
+		servers[i] = ServerResult{
+			Server:     backend.Server(),
+			Latency:    ping.avgLatency,
+			PacketLoss: ping.packetLoss,
+		}
+	}
+	return servers
+}
+
+// nearestServer 返回延迟最低的可达服务器下标，均不可达时返回 0
+func nearestServer(servers []ServerResult) int {
+	nearest := -1
+	for i, server := range servers {
+		if server.PacketLoss >= 100 {
+			continue
+		}
+		if nearest < 0 || server.Latency < servers[nearest].Latency {
+			nearest = i
+		}
+	}
+	return max(nearest, 0)
+}
@@ -8,12 +8,15 @@ import (
 	"math"
 	"net"
 	"net/http"
+	"net/netip"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/zhsama/clash-speedtest/logger"
 	"github.com/zhsama/clash-speedtest/unlock"
+	"github.com/zhsama/clash-speedtest/utils/geo"
 	"github.com/metacubex/mihomo/constant"
 )
 
@@ -27,9 +30,55 @@ func New(config *Config) *SpeedTester {
 	if config.UploadSize < 0 {
 		config.UploadSize = 10 * 1024 * 1024
 	}
+	if config.NodeConcurrent <= 0 {
+		config.NodeConcurrent = 1
+	}
+	if config.MaxSpeedTests <= 0 {
+		config.MaxSpeedTests = 1
+	}
 
 	st := &SpeedTester{
-		config: config,
+		config:     config,
+		speedSlots: make(chan struct{}, config.MaxSpeedTests),
+		names:      newNameRegistry(),
+		locations:  geo.Default(),
+	}
+
+	probers, err := NewLatencyProbers(config.LatencyProbes, config.ProbeURL, config.ProbeStatus)
+	if err != nil {
+		logger.Logger.Warn("Invalid latency probe configuration, probes disabled",
+			slog.String("error", err.Error()),
+		)
+	}
+	st.probers = probers
+
+	servers := config.BandwidthServers
+	if len(servers) == 0 {
+		servers = []string{config.ServerURL}
+	}
+	for _, server := range servers {
+		backend, err := NewBandwidthBackend(config.Backend, server)
+		if err != nil {
+			logger.Logger.Warn("Invalid bandwidth server configuration, server skipped",
+				slog.String("backend", config.Backend),
+				slog.String("server", server),
+				slog.String("error", err.Error()),
+			)
+			st.skippedServers = append(st.skippedServers, ServerResult{Server: server, PacketLoss: 100, Error: err.Error()})
+			continue
+		}
+		st.backends = append(st.backends, backend)
+	}
+	if len(st.backends) == 0 {
+		logger.Logger.Warn("No valid bandwidth server, using Cloudflare protocol on server URL",
+			slog.String("server_url", config.ServerURL),
+		)
+		st.backends = []BandwidthBackend{&cloudflareBackend{name: BackendCloudflare, base: config.ServerURL}}
+	}
+	if !st.backends[0].SupportsUpload() && config.UploadSize > 0 {
+		logger.Logger.Info("Bandwidth backend does not support upload, upload tests skipped",
+			slog.String("backend", st.backends[0].Name()),
+		)
 	}
 
 	if config.UnlockConfig != nil && config.UnlockConfig.Enabled {
@@ -54,9 +103,7 @@ func New(config *Config) *SpeedTester {
 }
 
 func (st *SpeedTester) TestProxies(proxies map[string]*CProxy, tester func(result *Result)) {
-	for name, proxy := range proxies {
-		tester(st.testProxy(name, proxy))
-	}
+	st.TestProxiesWithContext(context.Background(), proxies, tester)
 }
 
 // TestProxiesWithCallback is an alias for TestProxies for clarity in WebSocket context
@@ -64,23 +111,93 @@ func (st *SpeedTester) TestProxiesWithCallback(proxies map[string]*CProxy, callb
 	st.TestProxies(proxies, callback)
 }
 
-// TestProxiesWithContext tests proxies with context cancellation support
+// TestProxiesWithContext tests proxies with context cancellation support.
+// Up to Config.NodeConcurrent proxies are tested at once; callback invocations
+// are serialized so callers don't need their own locking.
 func (st *SpeedTester) TestProxiesWithContext(ctx context.Context, proxies map[string]*CProxy, callback func(result *Result)) error {
-	for name, proxy := range proxies {
-		// Check if context is cancelled
+	if st.config.RenameNodes {
+		st.names.reserve(proxyNames(proxies))
+	}
+
+	if st.config.Pipeline && st.testMode() != "unlock_only" {
+		return st.testProxiesPipelined(ctx, proxies, callback)
+	}
+
+	logger.Logger.Debug("Starting proxy worker pool",
+		slog.Int("proxy_count", len(proxies)),
+		slog.Int("node_concurrent", st.config.NodeConcurrent),
+		slog.Int("max_speed_tests", st.config.MaxSpeedTests),
+	)
+
+	var callbackMutex sync.Mutex
+	return st.runWorkerPool(ctx, proxyNames(proxies), func(name string) {
+		result := st.testProxy(ctx, name, proxies[name])
+
+		callbackMutex.Lock()
+		callback(result)
+		callbackMutex.Unlock()
+	})
+}
+
+// runWorkerPool 以 Config.NodeConcurrent 个 worker 并发处理节点，取消后不再派发新节点
+func (st *SpeedTester) runWorkerPool(ctx context.Context, names []string, work func(name string)) error {
+	workers := min(st.config.NodeConcurrent, len(names))
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				// 已取消时不再开始新的节点测试
+				if ctx.Err() != nil {
+					continue
+				}
+				work(name)
+			}
+		}()
+	}
+
+dispatch:
+	for _, name := range names {
 		select {
 		case <-ctx.Done():
-			logger.Logger.Info("Proxy testing cancelled", slog.String("reason", ctx.Err().Error()))
-			return ctx.Err()
-		default:
-			// Continue with the test
+			break dispatch
+		case jobs <- name:
 		}
+	}
+	close(jobs)
+	wg.Wait()
 
-		callback(st.testProxy(name, proxy))
+	if err := ctx.Err(); err != nil {
+		logger.Logger.Info("Proxy testing cancelled", slog.String("reason", err.Error()))
+		return err
 	}
 	return nil
 }
 
+// proxyNames 返回代理名称列表
+func proxyNames(proxies map[string]*CProxy) []string {
+	names := make([]string, 0, len(proxies))
+	for name := range proxies {
+		names = append(names, name)
+	}
+	return names
+}
+
+// acquireSpeedSlot 获取带宽测试槽位，返回释放函数；等待期间 ctx 取消时返回错误
+func (st *SpeedTester) acquireSpeedSlot(ctx context.Context) (func(), error) {
+	select {
+	case st.speedSlots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return func() {
+		<-st.speedSlots
+	}, nil
+}
+
 // FrontendUnlockResult 前端期望的解锁结果格式
 type FrontendUnlockResult struct {
 	Platform     string `json:"platform"`
@@ -118,6 +235,69 @@ type Result struct {
 	// 新增解锁检测结果字段 - 前端兼容格式
 	UnlockResults []FrontendUnlockResult `json:"unlock_results,omitempty"` // 解锁检测结果（前端格式）
 	UnlockSummary FrontendUnlockSummary  `json:"unlock_summary,omitempty"` // 解锁摘要（前端格式）
+	// 出口探测与节点重命名相关字段
+	EntryIP      string           `json:"entry_ip,omitempty"`      // 入口地址（server 解析后的 IP）
+	EgressIPv4   string           `json:"egress_ipv4,omitempty"`   // 通过代理探测到的出口 IPv4
+	EgressIPv6   string           `json:"egress_ipv6,omitempty"`   // 通过代理探测到的出口 IPv6
+	Relayed      bool             `json:"relayed"`                 // 出口与入口不同（中转/落地分离）
+	OriginalName string           `json:"original_name,omitempty"` // 重命名前的节点名称
+	ExitLocation *geo.GeoLocation `json:"exit_location,omitempty"` // 出口 IP 的地理位置
+	ASN          uint             `json:"asn,omitempty"`           // 出口 IP 所属自治系统编号
+	ASOrg        string           `json:"as_org,omitempty"`        // 自治系统所属组织
+	ASNType      string           `json:"asn_type,omitempty"`      // 自治系统分类：residential/datacenter/mobile/education
+	// 双栈连通性字段（开启 DualStack 时填充）
+	IPv4OK      bool          `json:"ipv4_ok,omitempty"`      // 通过代理可访问 IPv4 目标
+	IPv6OK      bool          `json:"ipv6_ok,omitempty"`      // 通过代理可访问 IPv6 目标
+	IPv4Latency time.Duration `json:"ipv4_latency,omitempty"` // IPv4 目标平均延迟
+	IPv6Latency time.Duration `json:"ipv6_latency,omitempty"` // IPv6 目标平均延迟
+	// UDP 测试字段（设置 UDPServer 时填充，不支持 UDP 的节点丢包率为 100）
+	UDPSupported  bool          `json:"udp_supported,omitempty"`   // 通过代理收到了 UDP 回包
+	UDPLatency    time.Duration `json:"udp_latency,omitempty"`     // UDP 平均往返时间
+	UDPPacketLoss float64       `json:"udp_packet_loss,omitempty"` // UDP 丢包率
+	// NAT 类型字段（设置 STUNServer 且节点支持 UDP 时填充）
+	NATType      string `json:"nat_type,omitempty"`      // 传统 NAT 类型：full-cone/restricted-cone/port-restricted-cone/symmetric
+	NATMapping   string `json:"nat_mapping,omitempty"`   // RFC 5780 映射行为
+	NATFiltering string `json:"nat_filtering,omitempty"` // RFC 5780 过滤行为
+	// 连接阶段耗时（延迟测试各次请求的平均值，复用连接的请求不计入建连与 TLS 阶段）
+	DialTime           time.Duration `json:"dial_time,omitempty"`            // 经代理建立到目标的连接，含代理协议握手
+	ProxyConnectTime   time.Duration `json:"proxy_connect_time,omitempty"`   // 到代理服务器的 TCP 连接（仅 mihomo 可替换拨号器的协议）
+	ProxyHandshakeTime time.Duration `json:"proxy_handshake_time,omitempty"` // 代理协议握手，即建连耗时减去 TCP 连接
+	TLSTime            time.Duration `json:"tls_time,omitempty"`             // 与测速服务器的 TLS 握手
+	WriteTime          time.Duration `json:"write_time,omitempty"`           // 写入请求
+	TTFB               time.Duration `json:"ttfb,omitempty"`                 // 请求写入完成到收到响应首字节
+	// 延迟分布字段（延迟测试成功请求的统计），用于区分偶发高延迟的节点与稳定节点
+	LatencyMin    time.Duration `json:"latency_min,omitempty"`    // 最小延迟
+	LatencyMedian time.Duration `json:"latency_median,omitempty"` // 延迟中位数
+	LatencyP90    time.Duration `json:"latency_p90,omitempty"`    // 延迟 90 百分位数
+	LatencyMax    time.Duration `json:"latency_max,omitempty"`    // 最大延迟
+	ArrivalJitter time.Duration `json:"arrival_jitter,omitempty"` // RFC 3550 到达间隔抖动（相邻延迟差值的平滑均值）
+	// 并列的延迟探测结果（配置 LatencyProbes 时填充）
+	Probes []ProbeResult `json:"probes,omitempty"`
+	// 定时测速字段（设置 TestDuration 时填充，此时 DownloadSpeed/UploadSpeed 为去除慢启动后的持续吞吐量）
+	SampleInterval time.Duration `json:"sample_interval,omitempty"`  // 采样周期
+	DownloadPeak   float64       `json:"download_peak,omitempty"`    // 单个采样周期的最高下载吞吐量
+	DownloadRampUp time.Duration `json:"download_ramp_up,omitempty"` // 下载中视为慢启动而丢弃的时长
+	DownloadSeries []float64     `json:"download_series,omitempty"`  // 各采样周期的下载吞吐量
+	UploadPeak     float64       `json:"upload_peak,omitempty"`      // 单个采样周期的最高上传吞吐量
+	UploadRampUp   time.Duration `json:"upload_ramp_up,omitempty"`   // 上传中视为慢启动而丢弃的时长
+	UploadSeries   []float64     `json:"upload_series,omitempty"`    // 各采样周期的上传吞吐量
+	// 多连接饱和测试字段（设置 SaturationStreams 时填充）
+	SaturationStages  []SaturationStage `json:"saturation_stages,omitempty"`   // 各阶段的连接数与吞吐量
+	SingleStreamSpeed float64           `json:"single_stream_speed,omitempty"` // 单连接吞吐量
+	MultiStreamSpeed  float64           `json:"multi_stream_speed,omitempty"`  // 各阶段中最高的总吞吐量
+	SaturationKnee    int               `json:"saturation_knee,omitempty"`     // 总吞吐量达到最高值 90% 所需的最少连接数
+	PerStreamLimited  bool              `json:"per_stream_limited,omitempty"`  // 多连接吞吐量达到单连接的 1.5 倍，节点可能按连接限速
+	// 负载下延迟字段（开启 Bufferbloat 时填充）
+	IdleLatency           time.Duration `json:"idle_latency,omitempty"`            // 空闲延迟（延迟测试的中位数）
+	LoadedDownloadLatency time.Duration `json:"loaded_download_latency,omitempty"` // 下载测试期间的延迟中位数
+	LoadedUploadLatency   time.Duration `json:"loaded_upload_latency,omitempty"`   // 上传测试期间的延迟中位数
+	BufferbloatGrade      string        `json:"bufferbloat_grade,omitempty"`       // 按延迟增量评定的等级：A+/A/B/C/D/F
+	// 带宽测试服务器字段（配置多个 BandwidthServers 时填充 ServerResults）
+	BandwidthServer string         `json:"bandwidth_server,omitempty"` // 产生下载与上传速度的服务器
+	ServerResults   []ServerResult `json:"server_results,omitempty"`   // 节点到各服务器的延迟及测试过的服务器的吞吐量
+	// 数据完整性字段（开启 VerifyPayload 时填充，校验失败的方向不报告速度）
+	PayloadVerified bool   `json:"payload_verified,omitempty"` // 有传输通过校验且没有校验失败
+	IntegrityError  string `json:"integrity_error,omitempty"`  // 数据损坏或截断的说明
 }
 
 func (r *Result) FormatDownloadSpeed() string {
@@ -157,13 +337,49 @@ func formatSpeed(bytesPerSecond float64) string {
 	return fmt.Sprintf("%.2f%s", speed, units[unit])
 }
 
-func (st *SpeedTester) testProxy(name string, proxy *CProxy) *Result {
+func (st *SpeedTester) testProxy(ctx context.Context, name string, proxy *CProxy) *Result {
 	logger.Logger.Debug("Starting proxy test",
 		slog.String("proxy_name", name),
 		slog.String("proxy_type", proxy.Type().String()),
 		slog.String("test_mode", st.config.TestMode),
 	)
 
+	result := newResult(name, proxy)
+
+	// 根据测试模式执行不同的测试
+	testMode := st.testMode()
+
+	// 1. 延迟测试（除非是仅解锁模式）
+	if testMode != "unlock_only" {
+		st.measureLatency(proxy, result)
+
+		// 如果延迟测试失败，且不是快速模式或解锁优先模式，则跳过后续测试
+		if testMode == "speed_only" && !st.passesLatency(result) {
+			logger.Logger.Info("Proxy failed latency test, skipping speed tests",
+				slog.String("proxy_name", name),
+				slog.Float64("packet_loss", result.PacketLoss),
+				slog.Int64("latency_ms", result.Latency.Milliseconds()),
+				slog.Int64("max_latency_ms", st.config.MaxLatency.Milliseconds()),
+			)
+			return result
+		}
+	}
+
+	st.finishProxyTest(ctx, proxy, result)
+	return result
+}
+
+// finishProxyTest 在延迟测试之后执行其余测试，单节点模式与两阶段模式共用
+func (st *SpeedTester) finishProxyTest(ctx context.Context, proxy *CProxy, result *Result) {
+	st.completeProxyTest(ctx, proxy, result)
+	st.testUDP(proxy, result)
+	st.testNAT(proxy, result)
+	st.probeEgress(proxy, result)
+	st.renameResult(result)
+}
+
+// newResult 创建仅包含节点基础信息的测试结果
+func newResult(name string, proxy *CProxy) *Result {
 	result := &Result{
 		ProxyName:   name,
 		ProxyType:   proxy.Type().String(),
@@ -175,38 +391,72 @@ func (st *SpeedTester) testProxy(name string, proxy *CProxy) *Result {
 		result.ProxyIP = server.(string)
 	}
 
+	return result
+}
+
+// testMode 返回生效的测试模式
+func (st *SpeedTester) testMode() string {
+	if st.config.TestMode == "" {
+		return "both" // 默认两者都测试
+	}
+	return st.config.TestMode
+}
+
+// passesLatency 判断延迟测试结果是否满足继续测速的要求
+func (st *SpeedTester) passesLatency(result *Result) bool {
+	if st.config.RequireIPv6 && !result.IPv6OK {
+		return false
+	}
+	return result.PacketLoss < 100 && result.Latency <= st.config.MaxLatency
+}
+
+// latencyFailureReason 说明节点未通过延迟要求的原因
+func (st *SpeedTester) latencyFailureReason(result *Result) string {
+	switch {
+	case result.PacketLoss >= 100:
+		return "all latency requests failed"
+	case st.config.RequireIPv6 && !result.IPv6OK:
+		return "IPv6 target unreachable through proxy"
+	default:
+		return fmt.Sprintf("latency %dms exceeds max %dms", result.Latency.Milliseconds(), st.config.MaxLatency.Milliseconds())
+	}
+}
+
+// measureLatency 执行延迟测试并写入结果
+func (st *SpeedTester) measureLatency(proxy *CProxy, result *Result) {
 	// 检查是否为 vless 协议，如果是则启用详细错误诊断
 	isVless := IsVlessProtocol(proxy.Type())
 	if isVless {
 		logger.Logger.Debug("Detected vless protocol, enabling enhanced error diagnostics",
-			slog.String("proxy_name", name),
+			slog.String("proxy_name", result.ProxyName),
 		)
 	}
 
-	// 根据测试模式执行不同的测试
-	testMode := st.config.TestMode
-	if testMode == "" {
-		testMode = "both" // 默认两者都测试
-	}
-
-	// 1. 延迟测试（除非是仅解锁模式）
-	if testMode != "unlock_only" {
-		latencyResult := st.testLatencyWithErrors(proxy, st.config.MaxLatency, isVless)
-		result.Latency = latencyResult.avgLatency
-		result.Jitter = latencyResult.jitter
-		result.PacketLoss = latencyResult.packetLoss
+	latencyResult := st.testLatencyWithErrors(proxy, st.config.MaxLatency, isVless)
+	result.Latency = latencyResult.avgLatency
+	result.Jitter = latencyResult.jitter
+	result.PacketLoss = latencyResult.packetLoss
+	result.DialTime = latencyResult.phases.dial
+	result.ProxyConnectTime = latencyResult.phases.proxyConnect
+	result.ProxyHandshakeTime = latencyResult.phases.proxyHandshake
+	result.TLSTime = latencyResult.phases.tls
+	result.WriteTime = latencyResult.phases.write
+	result.TTFB = latencyResult.phases.ttfb
+	result.LatencyMin = latencyResult.minLatency
+	result.LatencyMedian = latencyResult.medianLatency
+	result.LatencyP90 = latencyResult.p90Latency
+	result.LatencyMax = latencyResult.maxLatency
+	result.ArrivalJitter = latencyResult.arrivalJitter
+
+	st.probeDualStack(proxy, result)
+	st.runProbes(proxy, result)
+}
 
-		// 如果延迟测试失败，且不是快速模式或解锁优先模式，则跳过后续测试
-		if testMode == "speed_only" && (result.PacketLoss == 100 || result.Latency > st.config.MaxLatency) {
-			logger.Logger.Info("Proxy failed latency test, skipping speed tests",
-				slog.String("proxy_name", name),
-				slog.Float64("packet_loss", result.PacketLoss),
-				slog.Int64("latency_ms", result.Latency.Milliseconds()),
-				slog.Int64("max_latency_ms", st.config.MaxLatency.Milliseconds()),
-			)
-			return result
-		}
-	}
+// completeProxyTest 在延迟测试之后执行解锁检测和速度测试
+func (st *SpeedTester) completeProxyTest(ctx context.Context, proxy *CProxy, result *Result) {
+	name := result.ProxyName
+	testMode := st.testMode()
+	isVless := IsVlessProtocol(proxy.Type())
 
 	// 2. 解锁检测（除非是仅测速模式）
 	if testMode != "speed_only" && st.unlockDetector != nil {
@@ -222,7 +472,7 @@ func (st *SpeedTester) testProxy(name string, proxy *CProxy) *Result {
 
 		// 如果是仅解锁模式，直接返回结果
 		if testMode == "unlock_only" {
-			return result
+			return
 		}
 	} else if testMode != "speed_only" {
 		logger.Logger.Warn("Unlock detection requested but detector not initialized",
@@ -235,18 +485,32 @@ func (st *SpeedTester) testProxy(name string, proxy *CProxy) *Result {
 	// 3. 速度测试（除非是仅解锁模式或快速模式）
 	if testMode != "unlock_only" && !st.config.FastMode {
 		// 检查延迟是否满足要求（如果进行了延迟测试）
-		if testMode != "unlock_only" && (result.PacketLoss == 100 || result.Latency > st.config.MaxLatency) {
+		if !st.passesLatency(result) {
 			logger.Logger.Info("Proxy failed latency test, skipping speed tests",
 				slog.String("proxy_name", name),
 				slog.Float64("packet_loss", result.PacketLoss),
 				slog.Int64("latency_ms", result.Latency.Milliseconds()),
 				slog.Int64("max_latency_ms", st.config.MaxLatency.Milliseconds()),
 			)
-			return result
+			return
 		}
 
-		// 进行速度测试
-		st.performSpeedTests(proxy, result, isVless, name)
+		// 进行速度测试，受全局带宽测试槽位限制
+		release, err := st.acquireSpeedSlot(ctx)
+		if err != nil {
+			logger.Logger.Info("Proxy test cancelled while waiting for a bandwidth slot",
+				slog.String("proxy_name", name),
+				slog.String("reason", err.Error()),
+			)
+			result.FailureStage = PhaseBandwidth
+			result.FailureReason = "cancelled before bandwidth test"
+			return
+		}
+		defer release()
+
+		backend := st.testBandwidth(proxy, result, isVless, name)
+		st.gradeBufferbloat(result)
+		st.testSaturation(proxy, result, backend)
 	}
 
 	logger.Logger.Info("Proxy test completed successfully",
@@ -257,17 +521,21 @@ func (st *SpeedTester) testProxy(name string, proxy *CProxy) *Result {
 		slog.Float64("packet_loss", result.PacketLoss),
 		slog.Int("supported_platforms", result.UnlockSummary.TotalSupported),
 	)
-
-	return result
 }
 
-// performSpeedTests 执行速度测试
-func (st *SpeedTester) performSpeedTests(proxy *CProxy, result *Result, isVless bool, name string) {
+// performSpeedTests 使用指定服务器执行速度测试
+func (st *SpeedTester) performSpeedTests(proxy *CProxy, result *Result, backend BandwidthBackend, isVless bool, name string) {
+	if st.config.TestDuration > 0 {
+		st.performTimedSpeedTests(proxy, result, backend, isVless, name)
+		return
+	}
+
 	// 并发进行下载和上传测试
 	var wg sync.WaitGroup
 
+	// 各连接几乎同时开始，以最慢连接的耗时作为墙钟时间计算总吞吐量
 	var totalDownloadBytes, totalUploadBytes int64
-	var totalDownloadTime, totalUploadTime time.Duration
+	var longestDownload, longestUpload time.Duration
 	var downloadCount, uploadCount int
 
 	downloadChunkSize := st.config.DownloadSize / st.config.Concurrent
@@ -279,28 +547,32 @@ func (st *SpeedTester) performSpeedTests(proxy *CProxy, result *Result, isVless
 		)
 
 		downloadResults := make(chan *downloadResult, st.config.Concurrent)
+		integrity := st.newIntegrityCheck()
 
+		probe := st.startLoadProbe(proxy)
 		for i := 0; i < st.config.Concurrent; i++ {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				downloadResults <- st.testDownload(proxy, downloadChunkSize, st.config.Timeout)
+				downloadResults <- st.testDownload(proxy, backend, downloadChunkSize, st.config.Timeout, integrity)
 			}()
 		}
 		wg.Wait()
+		result.LoadedDownloadLatency = probe.stop()
 
 		for range st.config.Concurrent {
 			if dr := <-downloadResults; dr != nil {
 				totalDownloadBytes += dr.bytes
-				totalDownloadTime += dr.duration
+				longestDownload = max(longestDownload, dr.duration)
 				downloadCount++
 			}
 		}
 		close(downloadResults)
 
-		if downloadCount > 0 {
+		// 校验失败时不报告下载速度
+		if applyIntegrity(result, integrity, "download") && downloadCount > 0 {
 			result.DownloadSize = float64(totalDownloadBytes)
-			result.DownloadTime = totalDownloadTime / time.Duration(downloadCount)
+			result.DownloadTime = longestDownload
 			result.DownloadSpeed = float64(totalDownloadBytes) / result.DownloadTime.Seconds()
 
 			logger.Logger.Debug("Download test completed",
@@ -322,7 +594,7 @@ func (st *SpeedTester) performSpeedTests(proxy *CProxy, result *Result, isVless
 	}
 
 	uploadChunkSize := st.config.UploadSize / st.config.Concurrent
-	if uploadChunkSize > 0 {
+	if uploadChunkSize > 0 && backend.SupportsUpload() {
 		uploadConcurrent := st.config.Concurrent
 		if isVless && uploadConcurrent > 3 {
 			uploadConcurrent = 3
@@ -340,21 +612,24 @@ func (st *SpeedTester) performSpeedTests(proxy *CProxy, result *Result, isVless
 		)
 
 		uploadResults := make(chan *downloadResult, uploadConcurrent)
+		integrity := st.newIntegrityCheck()
 
+		probe := st.startLoadProbe(proxy)
 		for i := 0; i < uploadConcurrent; i++ {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				uploadResults <- st.testUpload(proxy, uploadChunkSize, st.config.Timeout)
+				uploadResults <- st.testUpload(proxy, backend, uploadChunkSize, st.config.Timeout, integrity)
 			}()
 		}
 		wg.Wait()
+		result.LoadedUploadLatency = probe.stop()
 
 		var failedUploads int
 		for i := 0; i < uploadConcurrent; i++ {
 			if ur := <-uploadResults; ur != nil {
 				totalUploadBytes += ur.bytes
-				totalUploadTime += ur.duration
+				longestUpload = max(longestUpload, ur.duration)
 				uploadCount++
 			} else {
 				failedUploads++
@@ -371,9 +646,14 @@ func (st *SpeedTester) performSpeedTests(proxy *CProxy, result *Result, isVless
 		)
 		close(uploadResults)
 
-		if uploadCount > 0 {
+		if !applyIntegrity(result, integrity, "upload") {
+			logger.Logger.Warn("Upload payload failed verification, upload speed not reported",
+				slog.String("proxy_name", name),
+				slog.String("error", result.IntegrityError),
+			)
+		} else if uploadCount > 0 {
 			result.UploadSize = float64(totalUploadBytes)
-			result.UploadTime = totalUploadTime / time.Duration(uploadCount)
+			result.UploadTime = longestUpload
 			result.UploadSpeed = float64(totalUploadBytes) / result.UploadTime.Seconds()
 
 			logger.Logger.Debug("Upload test completed",
@@ -388,7 +668,7 @@ func (st *SpeedTester) performSpeedTests(proxy *CProxy, result *Result, isVless
 				slog.String("proxy_type", proxy.Type().String()),
 				slog.Int("total_attempts", uploadConcurrent),
 				slog.Int("chunk_size_mb", uploadChunkSize/(1024*1024)),
-				slog.String("server_url", st.config.ServerURL),
+				slog.String("server_url", backend.Server()),
 				slog.String("timeout", st.config.Timeout.String()),
 				slog.String("possible_causes", "network timeout, proxy connection issues, server errors, or protocol incompatibility"),
 			)
@@ -405,24 +685,67 @@ func (st *SpeedTester) performSpeedTests(proxy *CProxy, result *Result, isVless
 	}
 }
 
+// 延迟测试默认参数
+const (
+	DefaultPingCount      = 6                      // 每个节点的请求次数
+	DefaultVlessPingCount = 3                      // VLESS 节点的默认请求次数，避免压垮慢速连接
+	DefaultPingInterval   = 100 * time.Millisecond // 请求间隔
+)
+
 type latencyResult struct {
-	avgLatency time.Duration
-	jitter     time.Duration
-	packetLoss float64
-	lastError  error // 添加最后一次错误信息
+	avgLatency    time.Duration
+	jitter        time.Duration
+	packetLoss    float64
+	lastError     error        // 添加最后一次错误信息
+	phases        phaseTimings // 成功请求的各阶段平均耗时
+	minLatency    time.Duration
+	medianLatency time.Duration
+	p90Latency    time.Duration
+	maxLatency    time.Duration
+	arrivalJitter time.Duration // RFC 3550 到达间隔抖动
+}
+
+// pingCount 返回延迟测试的请求次数，未配置时 VLESS 节点使用较少的次数
+func (st *SpeedTester) pingCount(proxy constant.Proxy) int {
+	if st.config.PingCount > 0 {
+		return st.config.PingCount
+	}
+	if proxy.Type() == constant.Vless {
+		return DefaultVlessPingCount
+	}
+	return DefaultPingCount
+}
+
+// pingInterval 返回延迟测试的请求间隔
+func (st *SpeedTester) pingInterval() time.Duration {
+	if st.config.PingInterval > 0 {
+		return st.config.PingInterval
+	}
+	return DefaultPingInterval
+}
+
+// latencyURL 返回延迟测试地址，默认由第一个带宽测试服务器的后端决定（如 Cloudflare 协议的空下载）
+// 节点延迟在选择带宽测试服务器之前测量，未设置 LatencyURL 时使用第一个有效服务器；
+// 负载下延迟使用同一地址，以便与空闲延迟比较
+func (st *SpeedTester) latencyURL() string {
+	if st.config.LatencyURL != "" {
+		return st.config.LatencyURL
+	}
+	return st.backends[0].LatencyURL()
 }
 
 // testLatencyWithErrors 增强版延迟测试，包含详细错误信息
 func (st *SpeedTester) testLatencyWithErrors(proxy constant.Proxy, minLatency time.Duration, captureErrors bool) *latencyResult {
 	client := st.createClient(proxy, minLatency)
-	latencies := make([]time.Duration, 0, 6)
+	pingAttempts := st.pingCount(proxy)
+	interval := st.pingInterval()
+	target := st.latencyURL()
+	latencies := make([]time.Duration, 0, pingAttempts)
+	phases := make([]phaseTimings, 0, pingAttempts)
 	failedPings := 0
 	var lastError error
 
-	// For VLESS proxies, reduce the number of ping attempts to avoid overwhelming slow connections
-	pingAttempts := 6
-	if captureErrors && proxy.Type() == constant.Vless {
-		pingAttempts = 3 // Reduce to 3 attempts for VLESS
+	if st.config.PingCount <= 0 && proxy.Type() == constant.Vless {
 		logger.Logger.Debug("Using reduced ping attempts for VLESS",
 			slog.String("proxy_name", proxy.Name()),
 			slog.Int("attempts", pingAttempts),
@@ -430,10 +753,11 @@ func (st *SpeedTester) testLatencyWithErrors(proxy constant.Proxy, minLatency ti
 	}
 
 	for i := range pingAttempts {
-		time.Sleep(100 * time.Millisecond)
+		time.Sleep(interval)
 
+		trace := &phaseTrace{}
 		start := time.Now()
-		resp, err := client.Get(fmt.Sprintf("%s/__down?bytes=0", st.config.ServerURL))
+		resp, err := tracedGet(client, target, trace)
 		if err != nil {
 			if captureErrors {
 				lastError = err // 保存最后一次错误用于详细分析
@@ -458,6 +782,7 @@ func (st *SpeedTester) testLatencyWithErrors(proxy constant.Proxy, minLatency ti
 		resp.Body.Close()
 		if resp.StatusCode == http.StatusOK {
 			latencies = append(latencies, time.Since(start))
+			phases = append(phases, trace.timings())
 		} else {
 			if captureErrors && lastError == nil {
 				lastError = fmt.Errorf("HTTP status %d", resp.StatusCode)
@@ -473,6 +798,7 @@ func (st *SpeedTester) testLatencyWithErrors(proxy constant.Proxy, minLatency ti
 	}
 
 	result := calculateLatencyStats(latencies, failedPings, pingAttempts)
+	result.phases = averagePhases(phases)
 	if captureErrors {
 		result.lastError = lastError
 	}
@@ -484,17 +810,27 @@ type downloadResult struct {
 	duration time.Duration
 }
 
-func (st *SpeedTester) testDownload(proxy constant.Proxy, size int, timeout time.Duration) *downloadResult {
+func (st *SpeedTester) testDownload(proxy constant.Proxy, backend BandwidthBackend, size int, timeout time.Duration, integrity *integrityCheck) *downloadResult {
 	client := st.createClient(proxy, timeout)
 	start := time.Now()
 
 	logger.Logger.Debug("Starting download test request",
-		slog.String("server_url", st.config.ServerURL),
+		slog.String("server_url", backend.Server()),
+		slog.String("backend", backend.Name()),
 		slog.Int("size_bytes", size),
 		slog.String("timeout", timeout.String()),
 	)
 
-	resp, err := client.Get(fmt.Sprintf("%s/__down?bytes=%d", st.config.ServerURL, size))
+	req, check, err := st.downloadRequest(context.Background(), backend, size)
+	if err != nil {
+		logger.Logger.Debug("Failed to create download request",
+			slog.String("error", err.Error()),
+			slog.Int("size_bytes", size),
+		)
+		return nil
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		logger.Logger.Debug("Download test request failed",
 			slog.String("error", err.Error()),
@@ -504,7 +840,7 @@ func (st *SpeedTester) testDownload(proxy constant.Proxy, size int, timeout time
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	if !downloadStatusOK(resp.StatusCode) {
 		logger.Logger.Debug("Download test received non-200 status",
 			slog.Int("status_code", resp.StatusCode),
 			slog.Int("size_bytes", size),
@@ -512,9 +848,21 @@ func (st *SpeedTester) testDownload(proxy constant.Proxy, size int, timeout time
 		return nil
 	}
 
-	downloadBytes, _ := io.Copy(io.Discard, resp.Body)
+	downloadBytes, _ := io.Copy(io.Discard, check.wrap(io.LimitReader(resp.Body, int64(size))))
 	duration := time.Since(start)
 
+	if check != nil {
+		err := check.verify(downloadBytes)
+		integrity.record(err)
+		if err != nil {
+			logger.Logger.Warn("Download payload failed verification",
+				slog.String("proxy_name", proxy.Name()),
+				slog.String("error", err.Error()),
+			)
+			return nil
+		}
+	}
+
 	logger.Logger.Debug("Download test completed",
 		slog.Int64("downloaded_bytes", downloadBytes),
 		slog.String("duration", duration.String()),
@@ -527,22 +875,29 @@ func (st *SpeedTester) testDownload(proxy constant.Proxy, size int, timeout time
 	}
 }
 
-func (st *SpeedTester) testUpload(proxy constant.Proxy, size int, timeout time.Duration) *downloadResult {
+func (st *SpeedTester) testUpload(proxy constant.Proxy, backend BandwidthBackend, size int, timeout time.Duration, integrity *integrityCheck) *downloadResult {
 	client := st.createClient(proxy, timeout)
 
 	// 对于VLESS代理，使用更保守的上传策略
 	isVless := proxy.Type() == constant.Vless
 	var reader io.Reader
+	payload := st.uploadPayload(size)
 
 	if isVless {
 		chunkSize := 256 * 1024
 		delayBetween := 1 * time.Millisecond
-		reader = NewChunkedZeroReader(size, chunkSize, delayBetween)
+		if payload != nil {
+			reader = NewChunkedReader(payload, chunkSize, delayBetween)
+		} else {
+			reader = NewChunkedZeroReader(size, chunkSize, delayBetween)
+		}
 		logger.Logger.Debug("Using chunked reader for VLESS upload",
 			slog.String("proxy_name", proxy.Name()),
 			slog.Int("chunk_size", chunkSize),
 			slog.String("delay", delayBetween.String()),
 		)
+	} else if payload != nil {
+		reader = payload
 	} else {
 		reader = NewZeroReader(size)
 	}
@@ -550,12 +905,13 @@ func (st *SpeedTester) testUpload(proxy constant.Proxy, size int, timeout time.D
 	logger.Logger.Debug("Starting upload test request",
 		slog.String("proxy_name", proxy.Name()),
 		slog.String("proxy_type", proxy.Type().String()),
-		slog.String("server_url", st.config.ServerURL),
+		slog.String("server_url", backend.Server()),
+		slog.String("backend", backend.Name()),
 		slog.Int("size_bytes", size),
 		slog.String("timeout", timeout.String()),
 	)
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/__up", st.config.ServerURL), reader)
+	req, err := backend.UploadRequest(context.Background(), reader, size)
 	if err != nil {
 		logger.Logger.Warn("Failed to create upload request",
 			slog.String("proxy_name", proxy.Name()),
@@ -567,9 +923,6 @@ func (st *SpeedTester) testUpload(proxy constant.Proxy, size int, timeout time.D
 		return nil
 	}
 
-	req.Header.Set("Content-Type", "application/octet-stream")
-	req.Header.Set("Content-Length", strconv.Itoa(size))
-
 	if isVless {
 		req.Header.Set("Connection", "keep-alive")
 		req.Header.Set("Transfer-Encoding", "")
@@ -588,7 +941,7 @@ func (st *SpeedTester) testUpload(proxy constant.Proxy, size int, timeout time.D
 			slog.String("error", err.Error()),
 			slog.String("error_type", fmt.Sprintf("%T", err)),
 			slog.Int("size_bytes", size),
-			slog.String("server_url", st.config.ServerURL),
+			slog.String("server_url", backend.Server()),
 			slog.String("timeout", timeout.String()),
 		)
 		return nil
@@ -604,14 +957,27 @@ func (st *SpeedTester) testUpload(proxy constant.Proxy, size int, timeout time.D
 			slog.Int("status_code", resp.StatusCode),
 			slog.String("response", string(respBody[:n])),
 			slog.Int("size_bytes", size),
-			slog.String("server_url", st.config.ServerURL),
+			slog.String("server_url", backend.Server()),
 		)
 		return nil
 	}
 
 	duration := time.Since(start)
+	if verified, err := verifyUploadEcho(resp.Header, payload); verified {
+		integrity.record(err)
+		if err != nil {
+			logger.Logger.Warn("Upload payload failed verification",
+				slog.String("proxy_name", proxy.Name()),
+				slog.String("error", err.Error()),
+			)
+			return nil
+		}
+	}
+
 	var uploadedBytes int64
-	if isVless {
+	if payload != nil {
+		uploadedBytes = payload.WrittenBytes()
+	} else if isVless {
 		if czr, ok := reader.(*ChunkedZeroReader); ok {
 			uploadedBytes = czr.WrittenBytes()
 		} else {
@@ -664,10 +1030,17 @@ func (st *SpeedTester) createClient(proxy constant.Proxy, timeout time.Duration)
 				slog.Int("target_port", int(u16Port)),
 			)
 
-			conn, err := proxy.DialContext(ctx, &constant.Metadata{
+			metadata := &constant.Metadata{
 				Host:    host,
 				DstPort: u16Port,
-			})
+			}
+			// IP 字面量作为目标 IP 传递，确保按对应协议族连接
+			if ip, err := netip.ParseAddr(host); err == nil {
+				metadata.Host = ""
+				metadata.DstIP = ip
+			}
+
+			conn, err := dialProxy(ctx, proxy, metadata)
 
 			if err != nil {
 				logger.Logger.Debug("Connection failed via proxy",
@@ -734,9 +1107,35 @@ func calculateLatencyStats(latencies []time.Duration, failedPings int, totalAtte
 	variance /= float64(len(latencies))
 	result.jitter = time.Duration(math.Sqrt(variance))
 
+	// 计算分布与到达间隔抖动
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	result.minLatency = sorted[0]
+	result.medianLatency = percentile(sorted, 50)
+	result.p90Latency = percentile(sorted, 90)
+	result.maxLatency = sorted[len(sorted)-1]
+	result.arrivalJitter = interarrivalJitter(latencies)
+
 	return result
 }
 
+// percentile 按最近秩法返回已排序样本的第 p 百分位数
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
+
+// interarrivalJitter 按 RFC 3550 第 6.4.1 节计算抖动：J += (|D| - J) / 16，
+// D 为相邻两次往返时间之差，样本须按发送顺序排列
+func interarrivalJitter(latencies []time.Duration) time.Duration {
+	var jitter float64
+	for i := 1; i < len(latencies); i++ {
+		d := math.Abs(float64(latencies[i] - latencies[i-1]))
+		jitter += (d - jitter) / 16
+	}
+	return time.Duration(jitter)
+}
+
 // convertToFrontendUnlockResults 将后端unlock结果转换为前端期望的格式
 func convertToFrontendUnlockResults(backendResults []unlock.UnlockResult) []FrontendUnlockResult {
 	frontendResults := make([]FrontendUnlockResult, len(backendResults))
@@ -0,0 +1,99 @@
+package speedtester
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// ms 将毫秒数列表转换为时长
+func ms(values ...float64) []time.Duration {
+	durations := make([]time.Duration, len(values))
+	for i, v := range values {
+		durations[i] = time.Duration(v * float64(time.Millisecond))
+	}
+	return durations
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{"single sample", ms(42), 50, 42 * time.Millisecond},
+		{"single sample p95", ms(42), 95, 42 * time.Millisecond},
+		{"p0 is minimum", ms(10, 20, 30, 40), 0, 10 * time.Millisecond},
+		{"median of even count", ms(10, 20, 30, 40), 50, 20 * time.Millisecond},
+		{"median of odd count", ms(10, 20, 30, 40, 50), 50, 30 * time.Millisecond},
+		{"p90 of ten", ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 90, 9 * time.Millisecond},
+		{"p95 rounds up", ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 95, 10 * time.Millisecond},
+		{"p100 is maximum", ms(10, 20, 30), 100, 30 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("percentile(%v, %v) = %v, want %v", tt.sorted, tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterarrivalJitter(t *testing.T) {
+	tests := []struct {
+		name      string
+		latencies []time.Duration
+		want      time.Duration
+	}{
+		{"no samples", nil, 0},
+		{"single sample", ms(50), 0},
+		{"constant latency", ms(50, 50, 50, 50), 0},
+		// J = 10ms / 16
+		{"one step", ms(10, 20), 625 * time.Microsecond},
+		// 方向不影响结果
+		{"one step down", ms(20, 10), 625 * time.Microsecond},
+		// J = 625µs + (10ms - 625µs) / 16
+		{"two steps", ms(10, 20, 10), 1210937 * time.Nanosecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := interarrivalJitter(tt.latencies); got != tt.want {
+				t.Errorf("interarrivalJitter(%v) = %v, want %v", tt.latencies, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterarrivalJitterOrder(t *testing.T) {
+	// 相同样本按不同顺序排列时抖动不同：交替变化的延迟抖动更大
+	steady := interarrivalJitter(ms(10, 10, 10, 30, 30, 30))
+	alternating := interarrivalJitter(ms(10, 30, 10, 30, 10, 30))
+	if alternating <= steady {
+		t.Errorf("alternating jitter %v should exceed steady jitter %v", alternating, steady)
+	}
+}
+
+func TestAcquireSpeedSlotCancelled(t *testing.T) {
+	st := &SpeedTester{speedSlots: make(chan struct{}, 1)}
+
+	release, err := st.acquireSpeedSlot(context.Background())
+	if err != nil {
+		t.Fatalf("acquireSpeedSlot() error: %v", err)
+	}
+
+	// 槽位已满时等待应随 ctx 取消返回
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := st.acquireSpeedSlot(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquireSpeedSlot() on a full pool = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	release()
+	if _, err := st.acquireSpeedSlot(context.Background()); err != nil {
+		t.Errorf("acquireSpeedSlot() after release error: %v", err)
+	}
+}
@@ -0,0 +1,314 @@
+package speedtester
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/metacubex/mihomo/constant"
+)
+
+// 定时测速参数
+const (
+	DefaultSampleInterval = 200 * time.Millisecond // 默认采样周期
+	MinSampleInterval     = 100 * time.Millisecond // 最短采样周期
+	MaxSampleInterval     = 250 * time.Millisecond // 最长采样周期
+	rampUpThreshold       = 0.75                   // 吞吐量首次达到峰值的该比例之前视为慢启动
+)
+
+// timedResult 定时传输的采样结果，吞吐量单位为字节/秒
+type timedResult struct {
+	bytes     int64         // 采样期间传输的总字节数
+	duration  time.Duration // 采样总时长
+	sustained float64       // 去除慢启动后的平均吞吐量
+	peak      float64       // 单个采样周期的最高吞吐量
+	series    []float64     // 各采样周期的吞吐量
+	rampUp    time.Duration // 视为慢启动而丢弃的时长
+	streams   []int64       // 各连接传输的字节数
+}
+
+// transferFunc 在一条连接上执行一次传输，并将传输的字节数实时累加到 counter
+type transferFunc func(ctx context.Context, client *http.Client, counter *atomic.Int64) error
+
+// countingReader 将读取的字节数累加到共享计数器
+type countingReader struct {
+	reader  io.Reader
+	counter *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.counter.Add(int64(n))
+	return n, err
+}
+
+// sampleInterval 返回采样周期，限制在 MinSampleInterval 与 MaxSampleInterval 之间
+func (st *SpeedTester) sampleInterval() time.Duration {
+	if st.config.SampleInterval <= 0 {
+		return DefaultSampleInterval
+	}
+	return min(max(st.config.SampleInterval, MinSampleInterval), MaxSampleInterval)
+}
+
+// performTimedSpeedTests 在每个方向持续传输 TestDuration，按采样周期记录吞吐量
+func (st *SpeedTester) performTimedSpeedTests(proxy *CProxy, result *Result, backend BandwidthBackend, isVless bool, name string) {
+	result.SampleInterval = st.sampleInterval()
+
+	downloadChunkSize := st.config.DownloadSize / st.config.Concurrent
+	if downloadChunkSize > 0 {
+		logger.Logger.Debug("Starting timed download test",
+			slog.String("proxy_name", name),
+			slog.String("duration", st.config.TestDuration.String()),
+			slog.Int("concurrent", st.config.Concurrent),
+		)
+
+		probe := st.startLoadProbe(proxy)
+		integrity := st.newIntegrityCheck()
+		download := st.timedTransfer(proxy, st.config.Concurrent, st.config.TestDuration, st.timedDownload(backend, downloadChunkSize, integrity))
+		result.LoadedDownloadLatency = probe.stop()
+		// 校验失败时不报告下载速度
+		if applyIntegrity(result, integrity, "download") && download != nil {
+			result.DownloadSize = float64(download.bytes)
+			result.DownloadTime = download.duration
+			result.DownloadSpeed = download.sustained
+			result.DownloadPeak = download.peak
+			result.DownloadSeries = download.series
+			result.DownloadRampUp = download.rampUp
+
+			logger.Logger.Debug("Timed download test completed",
+				slog.String("proxy_name", name),
+				slog.Int64("total_bytes", download.bytes),
+				slog.Float64("sustained_mbps", download.sustained/(1024*1024)),
+				slog.Float64("peak_mbps", download.peak/(1024*1024)),
+				slog.String("ramp_up", download.rampUp.String()),
+			)
+		}
+
+		if result.DownloadSpeed < st.config.MinDownloadSpeed {
+			logger.Logger.Info("Proxy failed minimum download speed requirement",
+				slog.String("proxy_name", name),
+				slog.Float64("actual_speed_mbps", result.DownloadSpeed/(1024*1024)),
+				slog.Float64("min_speed_mbps", st.config.MinDownloadSpeed/(1024*1024)),
+			)
+			return
+		}
+	}
+
+	uploadConcurrent := st.config.Concurrent
+	if isVless && uploadConcurrent > 3 {
+		uploadConcurrent = 3
+	}
+	uploadChunkSize := st.config.UploadSize / uploadConcurrent
+	if uploadChunkSize > 0 && backend.SupportsUpload() {
+		logger.Logger.Debug("Starting timed upload test",
+			slog.String("proxy_name", name),
+			slog.String("duration", st.config.TestDuration.String()),
+			slog.Int("concurrent", uploadConcurrent),
+		)
+
+		probe := st.startLoadProbe(proxy)
+		integrity := st.newIntegrityCheck()
+		upload := st.timedTransfer(proxy, uploadConcurrent, st.config.TestDuration, st.timedUpload(backend, uploadChunkSize, isVless, integrity))
+		result.LoadedUploadLatency = probe.stop()
+		if applyIntegrity(result, integrity, "upload") && upload != nil {
+			result.UploadSize = float64(upload.bytes)
+			result.UploadTime = upload.duration
+			result.UploadSpeed = upload.sustained
+			result.UploadPeak = upload.peak
+			result.UploadSeries = upload.series
+			result.UploadRampUp = upload.rampUp
+
+			logger.Logger.Debug("Timed upload test completed",
+				slog.String("proxy_name", name),
+				slog.Int64("total_bytes", upload.bytes),
+				slog.Float64("sustained_mbps", upload.sustained/(1024*1024)),
+				slog.Float64("peak_mbps", upload.peak/(1024*1024)),
+				slog.String("ramp_up", upload.rampUp.String()),
+			)
+		}
+
+		if result.UploadSpeed < st.config.MinUploadSpeed {
+			logger.Logger.Info("Proxy failed minimum upload speed requirement",
+				slog.String("proxy_name", name),
+				slog.Float64("actual_speed_mbps", result.UploadSpeed/(1024*1024)),
+				slog.Float64("min_speed_mbps", st.config.MinUploadSpeed/(1024*1024)),
+			)
+		}
+	}
+}
+
+// timedTransfer 使用 streams 条并发连接持续传输 duration，每条连接在一次传输完成后立即发起下一次。
+// 吞吐量按墙钟时间统计所有连接的总字节数，没有传输任何数据时返回 nil
+func (st *SpeedTester) timedTransfer(proxy constant.Proxy, streams int, duration time.Duration, transfer transferFunc) *timedResult {
+	interval := st.sampleInterval()
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+
+	counters := make([]atomic.Int64, streams)
+
+	var wg sync.WaitGroup
+	for i := range streams {
+		counter := &counters[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := st.createClient(proxy, duration+st.config.Timeout)
+			defer client.CloseIdleConnections()
+
+			for ctx.Err() == nil {
+				if err := transfer(ctx, client, counter); err != nil && ctx.Err() == nil {
+					if isIntegrityError(err) {
+						logger.Logger.Warn("Timed transfer payload failed verification",
+							slog.String("proxy_name", proxy.Name()),
+							slog.String("error", err.Error()),
+						)
+						return
+					}
+					logger.Logger.Debug("Timed transfer stream failed",
+						slog.String("proxy_name", proxy.Name()),
+						slog.String("error", err.Error()),
+					)
+					return
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(interval)
+	start := time.Now()
+	last := start
+	var lastBytes int64
+	perStream := make([]int64, streams)
+	series := make([]float64, 0, int(duration/interval)+1)
+
+sampling:
+	for {
+		select {
+		case now := <-ticker.C:
+			// 只统计到最后一个采样点，之后仍在途的数据不计入
+			var bytes int64
+			for i := range counters {
+				perStream[i] = counters[i].Load()
+				bytes += perStream[i]
+			}
+			series = append(series, float64(bytes-lastBytes)/now.Sub(last).Seconds())
+			last, lastBytes = now, bytes
+		case <-ctx.Done():
+			break sampling
+		}
+	}
+	ticker.Stop()
+	wg.Wait()
+
+	if lastBytes == 0 {
+		return nil
+	}
+
+	sustained, peak, rampUp := analyzeThroughput(series)
+	return &timedResult{
+		bytes:     lastBytes,
+		duration:  last.Sub(start),
+		sustained: sustained,
+		peak:      peak,
+		series:    series,
+		rampUp:    time.Duration(rampUp) * interval,
+		streams:   perStream,
+	}
+}
+
+// analyzeThroughput 丢弃吞吐量首次达到峰值 rampUpThreshold 之前的慢启动采样（最多丢弃一半），
+// 返回其余采样的平均吞吐量、峰值吞吐量与丢弃的采样数
+func analyzeThroughput(series []float64) (sustained, peak float64, rampUp int) {
+	if len(series) == 0 {
+		return 0, 0, 0
+	}
+
+	peak = slices.Max(series)
+	rampUp = len(series) / 2
+	for i, sample := range series {
+		if sample >= peak*rampUpThreshold {
+			rampUp = min(i, rampUp)
+			break
+		}
+	}
+
+	steady := series[rampUp:]
+	var total float64
+	for _, sample := range steady {
+		total += sample
+	}
+	return total / float64(len(steady)), peak, rampUp
+}
+
+// timedDownload 返回从 backend 下载 size 字节的传输函数，完整的传输按 integrity 校验
+func (st *SpeedTester) timedDownload(backend BandwidthBackend, size int, integrity *integrityCheck) transferFunc {
+	return func(ctx context.Context, client *http.Client, counter *atomic.Int64) error {
+		req, check, err := st.downloadRequest(ctx, backend, size)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if !downloadStatusOK(resp.StatusCode) {
+			return fmt.Errorf("HTTP status %d", resp.StatusCode)
+		}
+		n, err := io.Copy(io.Discard, &countingReader{reader: check.wrap(io.LimitReader(resp.Body, int64(size))), counter: counter})
+		if err != nil && ctx.Err() != nil {
+			return err // 测速时间结束时中断的传输不校验
+		}
+		if check != nil {
+			verifyErr := check.verify(n)
+			integrity.record(verifyErr)
+			if verifyErr != nil {
+				return verifyErr
+			}
+		}
+		return err
+	}
+}
+
+// timedUpload 返回向 backend 上传 size 字节的传输函数，VLESS 节点使用分块读取，服务器回显的校验和按 integrity 校验
+func (st *SpeedTester) timedUpload(backend BandwidthBackend, size int, isVless bool, integrity *integrityCheck) transferFunc {
+	return func(ctx context.Context, client *http.Client, counter *atomic.Int64) error {
+		var reader io.Reader = NewZeroReader(size)
+		payload := st.uploadPayload(size)
+		if payload != nil {
+			reader = payload
+		}
+		if isVless {
+			reader = NewChunkedReader(reader, 256*1024, time.Millisecond)
+		}
+
+		req, err := backend.UploadRequest(ctx, &countingReader{reader: reader, counter: counter}, size)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("HTTP status %d", resp.StatusCode)
+		}
+		if verified, verifyErr := verifyUploadEcho(resp.Header, payload); verified {
+			integrity.record(verifyErr)
+			return verifyErr
+		}
+		return nil
+	}
+}
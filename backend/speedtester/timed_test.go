@@ -0,0 +1,33 @@
+package speedtester
+
+import "testing"
+
+func TestAnalyzeThroughput(t *testing.T) {
+	tests := []struct {
+		name          string
+		series        []float64
+		wantSustained float64
+		wantPeak      float64
+		wantRampUp    int
+	}{
+		{"no samples", nil, 0, 0, 0},
+		{"single sample", []float64{5}, 5, 5, 0},
+		{"flat", []float64{10, 10, 10}, 10, 10, 0},
+		{"slow start discarded", []float64{2, 4, 10, 10, 10, 10}, 10, 10, 2},
+		// 峰值的 75% 恰好达到阈值
+		{"threshold inclusive", []float64{1, 7.5, 10, 7.5}, 25.0 / 3, 10, 1},
+		// 慢启动超过一半时最多丢弃一半采样
+		{"ramp-up capped at half", []float64{0, 0, 0, 0, 6, 9}, 5, 9, 3},
+		{"peak first", []float64{10, 4, 4, 2}, 5, 10, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sustained, peak, rampUp := analyzeThroughput(tt.series)
+			if sustained != tt.wantSustained || peak != tt.wantPeak || rampUp != tt.wantRampUp {
+				t.Errorf("analyzeThroughput(%v) = (%v, %v, %d), want (%v, %v, %d)",
+					tt.series, sustained, peak, rampUp, tt.wantSustained, tt.wantPeak, tt.wantRampUp)
+			}
+		})
+	}
+}
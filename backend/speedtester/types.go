@@ -4,34 +4,73 @@ import (
 	"time"
 
 	"github.com/zhsama/clash-speedtest/unlock"
+	"github.com/zhsama/clash-speedtest/utils/geo"
 	"github.com/metacubex/mihomo/constant"
 )
 
 // Config speed test configuration
 type Config struct {
-	ConfigPaths      string
-	FilterRegex      string
-	IncludeNodes     []string
-	ExcludeNodes     []string
-	ProtocolFilter   []string
-	ServerURL        string
-	DownloadSize     int
-	UploadSize       int
-	Timeout          time.Duration
-	Concurrent       int
-	MaxLatency       time.Duration
-	MinDownloadSpeed float64
-	MinUploadSpeed   float64
-	FastMode         bool
-	RenameNodes      bool
-	TestMode         string
-	UnlockConfig     *unlock.UnlockTestConfig
+	ConfigPaths       string
+	FilterRegex       string
+	IncludeNodes      []string
+	ExcludeNodes      []string
+	ProtocolFilter    []string
+	ServerURL         string
+	Backend           string   // 带宽测试后端（见 BandwidthBackends），为空时使用 Cloudflare 协议；file 后端的 ServerURL 为文件地址
+	BandwidthServers  []string // 多个带宽测试服务器（均使用 Backend 协议），为空时只使用 ServerURL
+	ServerSelection   string   // 多个服务器时的选择方式：ServerSelectNearest（默认）或 ServerSelectAll
+	DownloadSize      int
+	UploadSize        int
+	TestDuration      time.Duration // 每个方向的定时测速时长，为 0 时按 DownloadSize/UploadSize 传输固定字节数
+	SampleInterval    time.Duration // 定时测速的采样周期，为 0 时使用 DefaultSampleInterval
+	SaturationStreams int           // 饱和测试的最大并发连接数，为 0 时不进行饱和测试
+	SaturationStage   time.Duration // 饱和测试每个阶段的时长，为 0 时使用 DefaultSaturationStage
+	Bufferbloat       bool          // 带宽测试期间持续测量延迟，评定负载下的延迟劣化
+	VerifyPayload     bool          // 使用种子伪随机数据测速并校验完整性（下载需 download-server 后端，上传需服务器回显校验和）
+	Timeout           time.Duration
+	Concurrent        int
+	NodeConcurrent    int  // 同时测试的节点数，与单节点下载并发 Concurrent 相互独立
+	MaxSpeedTests     int  // 同时进行带宽测试的节点数上限，避免并行下载互相影响测速结果
+	Pipeline          bool // 两阶段模式：先筛选延迟，再对筛选出的节点测速
+	TopN              int  // 两阶段模式下进入带宽测试的节点数上限，0 表示不限制
+	MaxLatency        time.Duration
+	PingCount         int           // 延迟测试请求次数，为 0 时使用 DefaultPingCount（VLESS 为 DefaultVlessPingCount）
+	PingInterval      time.Duration // 延迟测试请求间隔，为 0 时使用 DefaultPingInterval
+	LatencyURL        string        // 延迟测试地址，为空时由带宽测试后端决定（Cloudflare 协议为 /__down?bytes=0）
+	LatencyProbes     []string      // 与主延迟并列报告的探测方式：http、tcp、tls
+	ProbeURL          string        // 探测地址，为空时使用 DefaultProbeURL，tcp/tls 探测连接其主机
+	ProbeStatus       string        // http 探测期望的状态码（如 "204"、"200-299"），为空时接受任意状态
+	MinDownloadSpeed  float64
+	MinUploadSpeed    float64
+	FastMode          bool
+	RenameNodes       bool
+	RenameTemplate    string   // 重命名模板，为空时使用 DefaultRenameTemplate
+	EgressProbe       bool     // 通过代理探测出口 IP 及其 ASN 分类（开启重命名时自动探测）
+	EgressEndpoints   []string // IPv4 回显服务，与 EgressEndpointsV6 均为空时使用默认列表
+	EgressEndpointsV6 []string // IPv6 回显服务
+	DualStack         bool     // 延迟测试时分别探测 IPv4 与 IPv6 连通性
+	RequireIPv6       bool     // 要求节点支持 IPv6，不支持时视为未通过延迟测试
+	IPv4Target        string   // 仅 IPv4 可达的探测地址，为空时使用 DefaultIPv4Target
+	IPv6Target        string   // 仅 IPv6 可达的探测地址，为空时使用 DefaultIPv6Target
+	UDPServer         string   // UDP 回显服务地址（host:port），为空时不进行 UDP 测试
+	UDPPackets        int      // UDP 测试发送的数据包数，为 0 时使用 DefaultUDPPackets
+	STUNServer        string   // 支持 RFC 5780 的 STUN 服务地址（host:port），为空时不检测 NAT 类型
+	TestMode          string
+	UnlockConfig      *unlock.UnlockTestConfig
 }
 
 // SpeedTester speed tester
 type SpeedTester struct {
 	config         *Config
 	unlockDetector *unlock.Detector
+	speedSlots     chan struct{} // 带宽测试信号量
+	locations      *geo.LocationCache
+	names          *nameRegistry // 重命名后的节点名称，用于去重
+	probers        []LatencyProber
+	backends       []BandwidthBackend // 各带宽测试服务器，至少一个
+	skippedServers []ServerResult     // 配置无效而跳过的带宽测试服务器，记录在多服务器结果中
+
+	progressHandler func(progress PhaseProgress)
 }
 
 // CProxy proxy configuration
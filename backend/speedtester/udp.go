@@ -0,0 +1,197 @@
+package speedtester
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/metacubex/mihomo/constant"
+)
+
+// DefaultUDPPackets is the number of echo datagrams sent per proxy
+const DefaultUDPPackets = 10
+
+const (
+	udpPacketInterval = 100 * time.Millisecond // 数据包发送间隔
+	udpPacketSize     = 64                     // 数据包大小：魔数 + 序号 + 填充
+)
+
+// udpMagic 标识测试数据包，用于忽略非本测试的回包
+var udpMagic = []byte("CSTUDP01")
+
+// udpEnabled 判断是否需要进行 UDP 测试
+func (st *SpeedTester) udpEnabled() bool {
+	return st.config.UDPServer != ""
+}
+
+// testUDP 通过代理向 UDP 回显服务发送数据包，记录 UDP 支持情况、往返延迟与丢包率
+func (st *SpeedTester) testUDP(proxy *CProxy, result *Result) {
+	if !st.udpEnabled() || result.PacketLoss >= 100 {
+		return
+	}
+
+	if !proxy.SupportUDP() {
+		result.UDPPacketLoss = 100
+		logger.Logger.Debug("Proxy does not support UDP",
+			slog.String("proxy_name", result.ProxyName),
+			slog.String("proxy_type", result.ProxyType),
+		)
+		return
+	}
+
+	packets := st.config.UDPPackets
+	if packets <= 0 {
+		packets = DefaultUDPPackets
+	}
+
+	latency, err := st.udpEcho(proxy.Proxy, packets)
+	if err != nil {
+		result.UDPPacketLoss = 100
+		logger.Logger.Debug("UDP test failed",
+			slog.String("proxy_name", result.ProxyName),
+			slog.String("udp_server", st.config.UDPServer),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	result.UDPSupported = latency.packetLoss < 100
+	result.UDPLatency = latency.avgLatency
+	result.UDPPacketLoss = latency.packetLoss
+
+	logger.Logger.Debug("UDP test completed",
+		slog.String("proxy_name", result.ProxyName),
+		slog.Bool("udp_supported", result.UDPSupported),
+		slog.Int64("udp_latency_ms", result.UDPLatency.Milliseconds()),
+		slog.Float64("udp_packet_loss", result.UDPPacketLoss),
+	)
+}
+
+// udpEcho 依次发送带序号的数据包，同时读取回包并按序号计算往返时间
+func (st *SpeedTester) udpEcho(proxy constant.Proxy, packets int) (*latencyResult, error) {
+	// 建立会话、发送全部数据包并等待回包的总时长
+	deadline := time.Now().Add(st.config.Timeout + time.Duration(packets)*udpPacketInterval + st.config.Timeout)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	target, err := resolveUDPTarget(ctx, st.config.UDPServer)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := proxy.ListenPacketContext(ctx, &constant.Metadata{
+		NetWork: constant.UDP,
+		DstIP:   target.Addr(),
+		DstPort: target.Port(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open UDP session: %w", err)
+	}
+	defer conn.Close()
+
+	// 最后一个包发出后再等待一个超时周期
+	conn.SetReadDeadline(deadline)
+
+	var mutex sync.Mutex
+	sentAt := make([]time.Time, packets)
+	rtts := make(map[uint32]time.Duration, packets)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 2048)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < len(udpMagic)+4 || !bytes.Equal(buf[:len(udpMagic)], udpMagic) {
+				continue
+			}
+			seq := binary.BigEndian.Uint32(buf[len(udpMagic):])
+
+			mutex.Lock()
+			if int(seq) < packets && !sentAt[seq].IsZero() {
+				if _, seen := rtts[seq]; !seen {
+					rtts[seq] = time.Since(sentAt[seq])
+				}
+			}
+			received := len(rtts)
+			mutex.Unlock()
+
+			if received == packets {
+				return
+			}
+		}
+	}()
+
+	addr := net.UDPAddrFromAddrPort(target)
+	payload := make([]byte, udpPacketSize)
+	copy(payload, udpMagic)
+	for i := range packets {
+		binary.BigEndian.PutUint32(payload[len(udpMagic):], uint32(i))
+
+		mutex.Lock()
+		sentAt[i] = time.Now()
+		mutex.Unlock()
+
+		if _, err := conn.WriteTo(payload, addr); err != nil {
+			logger.Logger.Debug("Failed to send UDP packet",
+				slog.String("proxy_name", proxy.Name()),
+				slog.Int("seq", i),
+				slog.String("error", err.Error()),
+			)
+		}
+		time.Sleep(udpPacketInterval)
+	}
+
+	<-done
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	// 按序号排列，到达间隔抖动依赖发送顺序
+	latencies := make([]time.Duration, 0, len(rtts))
+	for seq := range uint32(packets) {
+		if rtt, ok := rtts[seq]; ok {
+			latencies = append(latencies, rtt)
+		}
+	}
+	return calculateLatencyStats(latencies, packets-len(latencies), packets), nil
+}
+
+// resolveUDPTarget 解析 host:port 形式的 UDP 回显服务地址，优先使用 IPv4
+func resolveUDPTarget(ctx context.Context, server string) (netip.AddrPort, error) {
+	host, portStr, err := net.SplitHostPort(server)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("invalid UDP server %q: %w", server, err)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("invalid UDP server port %q", portStr)
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return netip.AddrPortFrom(addr.Unmap(), uint16(port)), nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return netip.AddrPort{}, fmt.Errorf("failed to resolve UDP server %s: %w", host, err)
+	}
+	addr := addrs[0]
+	for _, candidate := range addrs {
+		if candidate.Unmap().Is4() {
+			addr = candidate
+			break
+		}
+	}
+	return netip.AddrPortFrom(addr.Unmap(), uint16(port)), nil
+}
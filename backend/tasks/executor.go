@@ -10,7 +10,6 @@ import (
 	"github.com/zhsama/clash-speedtest/logger"
 	"github.com/zhsama/clash-speedtest/server/common"
 	"github.com/zhsama/clash-speedtest/speedtester"
-	"github.com/zhsama/clash-speedtest/unlock"
 )
 
 // Executor 任务执行器
@@ -121,29 +120,13 @@ func (e *Executor) waitForTaskCompletion(task Task) {
 	
 	for {
 		select {
-		case <-ctx.Done():
-			// 任务被取消
-			duration := time.Since(startTime)
-			logger.Logger.InfoContext(ctx, "Task cancelled",
-				slog.String("task_id", task.ID()),
-				slog.Duration("duration", duration),
-				slog.String("reason", ctx.Err().Error()))
-			
-			e.manager.EmitEvent(&TaskEvent{
-				TaskID:    task.ID(),
-				Type:      TaskEventTypeCancelled,
-				Data:      task.Progress(),
-				Timestamp: time.Now(),
-			})
+		case <-task.Done():
+			e.emitFinalEvent(ctx, task, time.Since(startTime))
 			return
 			
 		case <-ticker.C:
-			// 检查任务状态
-			status := task.Status()
-			duration := time.Since(startTime)
-			
 			// 发送进度更新
-			if status == TaskStatusRunning {
+			if task.Status() == TaskStatusRunning {
 				e.manager.EmitEvent(&TaskEvent{
 					TaskID:    task.ID(),
 					Type:      TaskEventTypeProgress,
@@ -151,44 +134,55 @@ func (e *Executor) waitForTaskCompletion(task Task) {
 					Timestamp: time.Now(),
 				})
 			}
-			
-			// 检查是否完成
-			if status == TaskStatusCompleted {
-				logger.Logger.InfoContext(ctx, "Task completed",
-					slog.String("task_id", task.ID()),
-					slog.Duration("duration", duration))
-				
-				e.manager.EmitEvent(&TaskEvent{
-					TaskID:    task.ID(),
-					Type:      TaskEventTypeCompleted,
-					Data:      task.Progress(),
-					Timestamp: time.Now(),
-				})
-				return
-			}
-			
-			// 检查是否失败
-			if status == TaskStatusFailed {
-				logger.Logger.ErrorContext(ctx, "Task failed",
-					slog.String("task_id", task.ID()),
-					slog.Duration("duration", duration),
-					slog.String("error", task.Error().Error()))
-				
-				e.manager.EmitEvent(&TaskEvent{
-					TaskID:    task.ID(),
-					Type:      TaskEventTypeFailed,
-					Data:      map[string]interface{}{
-						"error":    task.Error().Error(),
-						"progress": task.Progress(),
-					},
-					Timestamp: time.Now(),
-				})
-				return
-			}
 		}
 	}
 }
 
+// emitFinalEvent 根据任务最终状态发送结束事件
+func (e *Executor) emitFinalEvent(ctx context.Context, task Task, duration time.Duration) {
+	switch task.Status() {
+	case TaskStatusCompleted:
+		logger.Logger.InfoContext(ctx, "Task completed",
+			slog.String("task_id", task.ID()),
+			slog.Duration("duration", duration))
+		
+		e.manager.EmitEvent(&TaskEvent{
+			TaskID:    task.ID(),
+			Type:      TaskEventTypeCompleted,
+			Data:      task.Progress(),
+			Timestamp: time.Now(),
+		})
+		
+	case TaskStatusCancelled:
+		logger.Logger.InfoContext(ctx, "Task cancelled",
+			slog.String("task_id", task.ID()),
+			slog.Duration("duration", duration))
+		
+		e.manager.EmitEvent(&TaskEvent{
+			TaskID:    task.ID(),
+			Type:      TaskEventTypeCancelled,
+			Data:      task.Progress(),
+			Timestamp: time.Now(),
+		})
+		
+	case TaskStatusFailed:
+		logger.Logger.ErrorContext(ctx, "Task failed",
+			slog.String("task_id", task.ID()),
+			slog.Duration("duration", duration),
+			slog.String("error", task.Error().Error()))
+		
+		e.manager.EmitEvent(&TaskEvent{
+			TaskID:    task.ID(),
+			Type:      TaskEventTypeFailed,
+			Data:      map[string]interface{}{
+				"error":    task.Error().Error(),
+				"progress": task.Progress(),
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
+
 // SpeedTestTask 速度测试任务实现
 type SpeedTestTask struct {
 	id         string
@@ -198,10 +192,16 @@ type SpeedTestTask struct {
 	status     TaskStatus
 	options    *TaskOptions
 	startTime  time.Time
+	endTime    time.Time
 	progress   *TaskProgress
 	results    []*speedtester.Result
 	err        error
 	manager    *Manager
+	done       chan struct{}
+	
+	// 结果统计
+	successCount int
+	failedCount  int
 	
 	// 同步锁
 	mutex sync.RWMutex
@@ -254,6 +254,7 @@ func (t *SpeedTestTask) Start() error {
 	
 	t.status = TaskStatusRunning
 	t.startTime = time.Now()
+	t.progress.StartTime = t.startTime
 	
 	// 异步执行实际的测试逻辑
 	go t.executeTest()
@@ -289,10 +290,51 @@ func (t *SpeedTestTask) Progress() *TaskProgress {
 	defer t.mutex.RUnlock()
 	
 	progress := *t.progress
-	progress.Duration = time.Since(t.startTime)
+	progress.Duration = t.duration()
 	return &progress
 }
 
+// duration 返回任务已运行时长，结束后固定为总时长（调用方需持有锁）
+func (t *SpeedTestTask) duration() time.Duration {
+	if !t.endTime.IsZero() {
+		return t.endTime.Sub(t.startTime)
+	}
+	return time.Since(t.startTime)
+}
+
+// Done 返回任务结束时关闭的通道
+func (t *SpeedTestTask) Done() <-chan struct{} {
+	return t.done
+}
+
+// Snapshot 返回任务当前状态及结果的快照
+func (t *SpeedTestTask) Snapshot() *TaskResult {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	
+	progress := *t.progress
+	progress.Duration = t.duration()
+	
+	snapshot := &TaskResult{
+		TaskID:       t.id,
+		Type:         t.Type(),
+		Status:       t.status,
+		Config:       t.config,
+		Results:      t.results,
+		Progress:     &progress,
+		StartTime:    t.startTime,
+		CompleteTime: t.endTime,
+		Duration:     progress.Duration,
+		SuccessCount: t.successCount,
+		FailedCount:  t.failedCount,
+	}
+	if t.err != nil {
+		snapshot.Error = t.err.Error()
+	}
+	
+	return snapshot
+}
+
 // Error 返回任务错误
 func (t *SpeedTestTask) Error() error {
 	t.mutex.RLock()
@@ -304,44 +346,46 @@ func (t *SpeedTestTask) Error() error {
 func (t *SpeedTestTask) executeTest() {
 	ctx := t.ctx
 	
-	// 创建解锁配置
-	unlockConfig := t.createUnlockConfig()
+	defer func() {
+		t.mutex.Lock()
+		t.endTime = time.Now()
+		t.mutex.Unlock()
+		
+		t.manager.saveTask(t)
+		
+		// 释放上下文资源并通知执行器
+		t.cancelFunc()
+		close(t.done)
+	}()
+	
+	t.manager.saveTask(t)
 	
 	// 创建速度测试器
-	speedTester := speedtester.New(&speedtester.Config{
-		ConfigPaths:      t.config.ConfigPaths,
-		FilterRegex:      t.config.FilterRegex,
-		IncludeNodes:     t.config.IncludeNodes,
-		ExcludeNodes:     t.config.ExcludeNodes,
-		ProtocolFilter:   t.config.ProtocolFilter,
-		ServerURL:        t.config.ServerURL,
-		DownloadSize:     t.config.DownloadSize * 1024 * 1024,
-		UploadSize:       t.config.UploadSize * 1024 * 1024,
-		Timeout:          time.Duration(t.config.Timeout) * time.Second,
-		Concurrent:       t.config.Concurrent,
-		MaxLatency:       time.Duration(t.config.MaxLatency) * time.Millisecond,
-		MinDownloadSpeed: t.config.MinDownloadSpeed * 1024 * 1024,
-		MinUploadSpeed:   t.config.MinUploadSpeed * 1024 * 1024,
-		FastMode:         t.config.FastMode,
-		RenameNodes:      t.config.RenameNodes,
-		TestMode:         t.config.TestMode,
-		UnlockConfig:     unlockConfig,
-	})
+	speedTester := NewSpeedTester(t.config)
 	
 	// 加载代理
 	allProxies, err := speedTester.LoadProxies(t.config.StashCompatible)
 	if err != nil {
+		t.sendErrorMessage("Failed to load proxies: "+err.Error(), "PROXY_LOAD_ERROR")
 		t.setError(fmt.Errorf("failed to load proxies: %w", err))
 		return
 	}
 	
 	if len(allProxies) == 0 {
+		t.sendErrorMessage("No proxies found", "NO_PROXIES_FOUND")
 		t.setError(fmt.Errorf("no proxies found"))
 		return
 	}
 	
 	// 更新进度
 	t.updateProgress(0, len(allProxies), "")
+	speedTester.SetProgressHandler(func(progress speedtester.PhaseProgress) {
+		t.updatePhaseProgress(progress)
+		t.sendPhaseProgress(progress)
+	})
+	
+	// 发送测试开始消息
+	t.sendTestStartMessage(len(allProxies))
 	
 	// 执行测试
 	results := make([]*speedtester.Result, 0)
@@ -351,8 +395,24 @@ func (t *SpeedTestTask) executeTest() {
 		results = append(results, result)
 		completed++
 		
+		// 判断结果状态
+		status := DetermineResultStatus(result, t.config)
+		
 		// 更新进度
 		t.updateProgress(completed, len(allProxies), result.ProxyName)
+		t.mutex.Lock()
+		t.results = append(t.results, result)
+		if status == "success" {
+			t.successCount++
+		} else {
+			t.failedCount++
+		}
+		t.mutex.Unlock()
+		
+		t.manager.saveResult(t.id, result)
+		
+		// 发送进度及结果
+		t.sendProgressUpdate(result, completed, len(allProxies), status)
 		
 		// 发送结果事件
 		t.manager.EmitEvent(&TaskEvent{
@@ -370,48 +430,19 @@ func (t *SpeedTestTask) executeTest() {
 	
 	// 设置结果
 	t.mutex.Lock()
-	t.results = results
 	if err == context.Canceled {
 		t.status = TaskStatusCancelled
 	} else {
 		t.status = TaskStatusCompleted
 	}
+	successful, failed := t.successCount, t.failedCount
 	t.mutex.Unlock()
-}
-
-// createUnlockConfig 创建解锁配置
-func (t *SpeedTestTask) createUnlockConfig() *unlock.UnlockTestConfig {
-	needsUnlock := t.config.TestMode == "unlock_only" || t.config.TestMode == "both"
 	
-	if !t.config.UnlockEnabled && !needsUnlock {
-		return &unlock.UnlockTestConfig{
-			Enabled: false,
-		}
-	}
-	
-	platforms := t.config.UnlockPlatforms
-	if len(platforms) == 0 {
-		platforms = []string{"Netflix", "YouTube", "Disney+", "ChatGPT", "Spotify", "Bilibili"}
-	}
-	
-	concurrent := t.config.UnlockConcurrent
-	if concurrent <= 0 {
-		concurrent = 5
-	}
-	
-	timeout := t.config.UnlockTimeout
-	if timeout <= 0 {
-		timeout = 10
-	}
-	
-	return &unlock.UnlockTestConfig{
-		Enabled:       true,
-		Platforms:     platforms,
-		Concurrent:    concurrent,
-		Timeout:       timeout,
-		RetryOnError:  t.config.UnlockRetry,
-		IncludeIPInfo: true,
+	if err == context.Canceled {
+		t.sendTestCancelledMessage(completed, len(allProxies), time.Since(t.startTime))
+		return
 	}
+	t.sendTestCompleteMessage(results, successful, failed, time.Since(t.startTime))
 }
 
 // updateProgress 更新进度
@@ -428,6 +459,18 @@ func (t *SpeedTestTask) updateProgress(completed, total int, currentProxy string
 	}
 }
 
+// updatePhaseProgress 更新两阶段模式的阶段进度
+func (t *SpeedTestTask) updatePhaseProgress(progress speedtester.PhaseProgress) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	
+	t.progress.Phase = progress.Phase
+	t.progress.ScreeningCompleted = progress.ScreeningCompleted
+	t.progress.ScreeningTotal = progress.ScreeningTotal
+	t.progress.BandwidthCompleted = progress.BandwidthCompleted
+	t.progress.BandwidthTotal = progress.BandwidthTotal
+}
+
 // setError 设置错误
 func (t *SpeedTestTask) setError(err error) {
 	t.mutex.Lock()
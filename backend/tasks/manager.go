@@ -6,11 +6,13 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log/slog"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/zhsama/clash-speedtest/logger"
 	"github.com/zhsama/clash-speedtest/server/common"
+	"github.com/zhsama/clash-speedtest/speedtester"
 	"github.com/zhsama/clash-speedtest/websocket"
 )
 
@@ -20,6 +22,10 @@ type Manager struct {
 	tasksMutex sync.RWMutex
 	wsHub     *websocket.Hub
 	executor  *Executor
+	store     Store
+	retention time.Duration
+	// retained 返回清理时需要保留的任务 ID，例如定时任务最近一次成功的运行
+	retained func() map[string]bool
 	
 	// 事件处理
 	eventHandlers map[string][]TaskEventHandler
@@ -29,20 +35,90 @@ type Manager struct {
 // TaskEventHandler 任务事件处理器
 type TaskEventHandler func(event *TaskEvent)
 
-// NewManager 创建新的任务管理器
-func NewManager(wsHub *websocket.Hub) *Manager {
+// NewManager 创建新的任务管理器，store 为 nil 时任务仅保存在内存中
+func NewManager(wsHub *websocket.Hub, store Store) *Manager {
 	manager := &Manager{
 		tasks:         make(map[string]Task),
 		wsHub:         wsHub,
+		store:         store,
+		retention:     24 * time.Hour,
 		eventHandlers: make(map[string][]TaskEventHandler),
 	}
 	
 	// 创建任务执行器
 	manager.executor = NewExecutor(manager)
 	
+	// 恢复持久化的任务
+	manager.restoreTasks()
+	
 	return manager
 }
 
+// SetRetainedFunc 设置清理时需要保留的任务，fn 在清理加锁前调用
+func (m *Manager) SetRetainedFunc(fn func() map[string]bool) {
+	m.retained = fn
+}
+
+// SetRetention 设置已结束任务的保留时长
+func (m *Manager) SetRetention(retention time.Duration) {
+	if retention > 0 {
+		m.retention = retention
+	}
+}
+
+// restoreTasks 从存储中恢复任务，未结束的任务标记为中断
+func (m *Manager) restoreTasks() {
+	if m.store == nil {
+		return
+	}
+	
+	records, err := m.store.LoadTasks()
+	if err != nil {
+		logger.LogError("Failed to load stored tasks", err)
+		return
+	}
+	
+	interrupted := 0
+	for _, record := range records {
+		if !isFinished(record.Status) {
+			record.Status = TaskStatusInterrupted
+			record.Error = "task interrupted by server restart"
+			if err := m.store.SaveTask(record); err != nil {
+				logger.LogError("Failed to mark task as interrupted", err,
+					slog.String("task_id", record.TaskID))
+			}
+			interrupted++
+		}
+		m.tasks[record.TaskID] = newStoredTask(record)
+	}
+	
+	logger.Logger.Info("Stored tasks restored",
+		slog.Int("count", len(records)),
+		slog.Int("interrupted", interrupted))
+}
+
+// saveTask 持久化任务元数据
+func (m *Manager) saveTask(task Task) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.SaveTask(task.Snapshot()); err != nil {
+		logger.LogError("Failed to persist task", err, slog.String("task_id", task.ID()))
+	}
+}
+
+// saveResult 持久化单个测试结果
+func (m *Manager) saveResult(taskID string, result *speedtester.Result) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.AppendResult(taskID, result); err != nil {
+		logger.LogError("Failed to persist task result", err,
+			slog.String("task_id", taskID),
+			slog.String("proxy_name", result.ProxyName))
+	}
+}
+
 // generateTaskID 生成任务 ID
 func (m *Manager) generateTaskID() string {
 	bytes := make([]byte, 8)
@@ -60,7 +136,10 @@ func (m *Manager) CreateTask(req *common.TestRequest, options *TaskOptions) (Tas
 	taskID := m.generateTaskID()
 	
 	// 创建任务上下文
-	ctx, cancel := context.WithTimeout(context.Background(), options.Timeout)
+	ctx, cancel := context.WithCancel(context.Background())
+	if options.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), options.Timeout)
+	}
 	
 	// 创建任务
 	task := &SpeedTestTask{
@@ -73,6 +152,7 @@ func (m *Manager) CreateTask(req *common.TestRequest, options *TaskOptions) (Tas
 		startTime:  time.Now(),
 		progress:   &TaskProgress{},
 		manager:    m,
+		done:       make(chan struct{}),
 	}
 	
 	// 添加到任务列表
@@ -98,7 +178,7 @@ func (m *Manager) GetTask(taskID string) (Task, bool) {
 	return task, exists
 }
 
-// GetAllTasks 获取所有任务
+// GetAllTasks 获取所有任务，按开始时间从新到旧排序
 func (m *Manager) GetAllTasks() []Task {
 	m.tasksMutex.RLock()
 	defer m.tasksMutex.RUnlock()
@@ -108,6 +188,10 @@ func (m *Manager) GetAllTasks() []Task {
 		tasks = append(tasks, task)
 	}
 	
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Progress().StartTime.After(tasks[j].Progress().StartTime)
+	})
+	
 	return tasks
 }
 
@@ -122,6 +206,21 @@ func (m *Manager) StartTask(taskID string) error {
 	return m.executor.ExecuteTask(task)
 }
 
+// SubmitTask 创建并启动任务，执行器已满时移除该任务
+func (m *Manager) SubmitTask(req *common.TestRequest, options *TaskOptions) (Task, error) {
+	task, err := m.CreateTask(req, options)
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := m.StartTask(task.ID()); err != nil {
+		m.RemoveTask(task.ID())
+		return nil, err
+	}
+	
+	return task, nil
+}
+
 // CancelTask 取消任务
 func (m *Manager) CancelTask(taskID string) error {
 	task, exists := m.GetTask(taskID)
@@ -142,6 +241,7 @@ func (m *Manager) RemoveTask(taskID string) error {
 	}
 	
 	delete(m.tasks, taskID)
+	m.deleteStoredTask(taskID)
 	
 	logger.Logger.InfoContext(context.Background(), "Task removed",
 		slog.String("task_id", taskID))
@@ -151,6 +251,12 @@ func (m *Manager) RemoveTask(taskID string) error {
 
 // CleanupCompletedTasks 清理已完成的任务
 func (m *Manager) CleanupCompletedTasks(maxAge time.Duration) {
+	// 在加锁前获取需要保留的任务，retained 可能会查询任务状态
+	var retained map[string]bool
+	if m.retained != nil {
+		retained = m.retained()
+	}
+	
 	m.tasksMutex.Lock()
 	defer m.tasksMutex.Unlock()
 	
@@ -158,7 +264,7 @@ func (m *Manager) CleanupCompletedTasks(maxAge time.Duration) {
 	toDelete := make([]string, 0)
 	
 	for taskID, task := range m.tasks {
-		if task.Status() == TaskStatusCompleted || task.Status() == TaskStatusFailed {
+		if isFinished(task.Status()) && !retained[taskID] {
 			// 检查任务是否超过最大保留时间
 			if now.Sub(task.Progress().StartTime) > maxAge {
 				toDelete = append(toDelete, taskID)
@@ -168,6 +274,7 @@ func (m *Manager) CleanupCompletedTasks(maxAge time.Duration) {
 	
 	for _, taskID := range toDelete {
 		delete(m.tasks, taskID)
+		m.deleteStoredTask(taskID)
 		logger.Logger.DebugContext(context.Background(), "Cleaned up old task",
 			slog.String("task_id", taskID))
 	}
@@ -178,6 +285,16 @@ func (m *Manager) CleanupCompletedTasks(maxAge time.Duration) {
 	}
 }
 
+// deleteStoredTask 从存储中删除任务
+func (m *Manager) deleteStoredTask(taskID string) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.DeleteTask(taskID); err != nil {
+		logger.LogError("Failed to delete stored task", err, slog.String("task_id", taskID))
+	}
+}
+
 // AddEventHandler 添加事件处理器
 func (m *Manager) AddEventHandler(eventType string, handler TaskEventHandler) {
 	m.eventMutex.Lock()
@@ -201,9 +318,26 @@ func (m *Manager) EmitEvent(event *TaskEvent) {
 		go handler(event)
 	}
 	
-	// 通过 WebSocket 广播事件
+	// 通过 WebSocket 广播事件，单个结果已通过 test_result 消息发送
+	if event.Type != TaskEventTypeResult {
+		m.broadcast(event.TaskID, websocket.MessageType("task_"+event.Type), event)
+	}
+}
+
+// broadcast 通过 WebSocket 发送带任务 ID 的消息
+func (m *Manager) broadcast(taskID string, msgType websocket.MessageType, data any) {
 	if m.wsHub != nil {
-		m.wsHub.BroadcastMessage(websocket.MessageType(event.Type), event)
+		m.wsHub.BroadcastTaskMessage(taskID, msgType, data)
+	}
+}
+
+// isFinished 判断任务是否已结束
+func isFinished(status TaskStatus) bool {
+	switch status {
+	case TaskStatusCompleted, TaskStatusCancelled, TaskStatusFailed, TaskStatusInterrupted:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -213,12 +347,13 @@ func (m *Manager) GetStats() map[string]interface{} {
 	defer m.tasksMutex.RUnlock()
 	
 	stats := map[string]interface{}{
-		"total_tasks":     len(m.tasks),
-		"pending_tasks":   0,
-		"running_tasks":   0,
-		"completed_tasks": 0,
-		"cancelled_tasks": 0,
-		"failed_tasks":    0,
+		"total_tasks":       len(m.tasks),
+		"pending_tasks":     0,
+		"running_tasks":     0,
+		"completed_tasks":   0,
+		"cancelled_tasks":   0,
+		"failed_tasks":      0,
+		"interrupted_tasks": 0,
 	}
 	
 	for _, task := range m.tasks {
@@ -233,6 +368,8 @@ func (m *Manager) GetStats() map[string]interface{} {
 			stats["cancelled_tasks"] = stats["cancelled_tasks"].(int) + 1
 		case TaskStatusFailed:
 			stats["failed_tasks"] = stats["failed_tasks"].(int) + 1
+		case TaskStatusInterrupted:
+			stats["interrupted_tasks"] = stats["interrupted_tasks"].(int) + 1
 		}
 	}
 	
@@ -247,7 +384,7 @@ func (m *Manager) Start() {
 		defer ticker.Stop()
 		
 		for range ticker.C {
-			m.CleanupCompletedTasks(24 * time.Hour)
+			m.CleanupCompletedTasks(m.retention)
 		}
 	}()
 	
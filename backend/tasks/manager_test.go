@@ -0,0 +1,101 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zhsama/clash-speedtest/speedtester"
+)
+
+func TestRestoreTasks(t *testing.T) {
+	tests := []struct {
+		status          TaskStatus
+		wantStatus      TaskStatus
+		wantInterrupted bool
+	}{
+		{TaskStatusPending, TaskStatusInterrupted, true},
+		{TaskStatusRunning, TaskStatusInterrupted, true},
+		{TaskStatusCompleted, TaskStatusCompleted, false},
+		{TaskStatusCancelled, TaskStatusCancelled, false},
+		{TaskStatusFailed, TaskStatusFailed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			store := newTestStore(t)
+			store.SaveTask(&TaskResult{TaskID: "a", Status: tt.status, StartTime: time.Now()})
+			store.AppendResult("a", &speedtester.Result{ProxyName: "p1"})
+
+			manager := NewManager(nil, store)
+			task, ok := manager.GetTask("a")
+			if !ok {
+				t.Fatal("task not restored")
+			}
+			if task.Status() != tt.wantStatus {
+				t.Errorf("Status() = %q, want %q", task.Status(), tt.wantStatus)
+			}
+			if interrupted := task.Error() != nil; interrupted != tt.wantInterrupted {
+				t.Errorf("Error() = %v, want error %v", task.Error(), tt.wantInterrupted)
+			}
+			if len(task.Results()) != 1 {
+				t.Errorf("restored %d results, want 1", len(task.Results()))
+			}
+			select {
+			case <-task.Done():
+			default:
+				t.Error("restored task is not done")
+			}
+
+			// 中断状态需要写回存储，再次重启时保持不变
+			tasks, err := store.LoadTasks()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tasks) != 1 || tasks[0].Status != tt.wantStatus {
+				t.Errorf("stored tasks = %+v, want one task with status %q", tasks, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCleanupCompletedTasks(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour)
+	recent := time.Now()
+
+	tests := []struct {
+		name      string
+		status    TaskStatus
+		startTime time.Time
+		retained  bool
+		wantKept  bool
+	}{
+		{"old completed task", TaskStatusCompleted, old, false, false},
+		{"old interrupted task", TaskStatusRunning, old, false, false},
+		{"recent completed task", TaskStatusCompleted, recent, false, true},
+		{"old retained task", TaskStatusCompleted, old, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			store.SaveTask(&TaskResult{TaskID: "a", Status: tt.status, StartTime: tt.startTime})
+
+			manager := NewManager(nil, store)
+			manager.SetRetainedFunc(func() map[string]bool {
+				return map[string]bool{"a": tt.retained}
+			})
+			manager.CleanupCompletedTasks(time.Hour)
+
+			if _, kept := manager.GetTask("a"); kept != tt.wantKept {
+				t.Errorf("task kept in manager = %v, want %v", kept, tt.wantKept)
+			}
+			tasks, err := store.LoadTasks()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kept := len(tasks) == 1; kept != tt.wantKept {
+				t.Errorf("task kept in store = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package tasks
+
+import (
+	"time"
+
+	"github.com/zhsama/clash-speedtest/server/common"
+	"github.com/zhsama/clash-speedtest/speedtester"
+	"github.com/zhsama/clash-speedtest/websocket"
+)
+
+// DetermineResultStatus 根据测试配置判断结果状态
+func DetermineResultStatus(result *speedtester.Result, config *common.TestRequest) string {
+	if config.TestMode == "unlock_only" {
+		if result.UnlockSummary.TotalSupported > 0 {
+			return "success"
+		}
+		return "failed"
+	}
+	
+	if result.PacketLoss == 100 || result.Latency > time.Duration(config.MaxLatency)*time.Millisecond {
+		return "failed"
+	}
+
+	if config.RequireIPv6 && !result.IPv6OK {
+		return "failed"
+	}
+
+	if result.IntegrityError != "" {
+		return "failed"
+	}
+	
+	if result.DownloadSpeed < config.MinDownloadSpeed*1024*1024 || result.UploadSpeed < config.MinUploadSpeed*1024*1024 {
+		return "failed"
+	}
+	
+	return "success"
+}
+
+// sendTestStartMessage 发送测试开始消息
+func (t *SpeedTestTask) sendTestStartMessage(totalProxies int) {
+	testStartData := websocket.TestStartData{
+		TotalProxies: totalProxies,
+	}
+	
+	// 设置配置信息
+	testStartData.Config.ConfigPaths = t.config.ConfigPaths
+	testStartData.Config.FilterRegex = t.config.FilterRegex
+	testStartData.Config.ServerURL = t.config.ServerURL
+	testStartData.Config.DownloadSize = t.config.DownloadSize
+	testStartData.Config.UploadSize = t.config.UploadSize
+	testStartData.Config.Timeout = t.config.Timeout
+	testStartData.Config.Concurrent = t.config.Concurrent
+	testStartData.Config.NodeConcurrent = t.config.NodeConcurrent
+	testStartData.Config.MaxSpeedTests = t.config.MaxSpeedTests
+	testStartData.Config.MaxLatency = t.config.MaxLatency
+	testStartData.Config.MinDownloadSpeed = t.config.MinDownloadSpeed
+	testStartData.Config.MinUploadSpeed = t.config.MinUploadSpeed
+	testStartData.Config.StashCompatible = t.config.StashCompatible
+	
+	t.manager.broadcast(t.id, websocket.MessageTypeTestStart, testStartData)
+}
+
+// sendProgressUpdate 发送进度更新
+func (t *SpeedTestTask) sendProgressUpdate(result *speedtester.Result, completed, total int, status string) {
+	progressData := websocket.TestProgressData{
+		CurrentProxy:    result.ProxyName,
+		CompletedCount:  completed,
+		TotalCount:      total,
+		ProgressPercent: float64(completed) / float64(total) * 100,
+		Status:          status,
+	}
+	t.manager.broadcast(t.id, websocket.MessageTypeTestProgress, progressData)
+	
+	// 发送单个结果
+	resultData := websocket.TestResultData{
+		ProxyName:         result.ProxyName,
+		ProxyType:         result.ProxyType,
+		ProxyIP:           result.ProxyIP,
+		Latency:           result.Latency.Milliseconds(),
+		Jitter:            result.Jitter.Milliseconds(),
+		PacketLoss:        result.PacketLoss,
+		DownloadSpeed:     result.DownloadSpeed,
+		UploadSpeed:       result.UploadSpeed,
+		DownloadSpeedMbps: result.DownloadSpeed / (1024 * 1024),
+		UploadSpeedMbps:   result.UploadSpeed / (1024 * 1024),
+		Status:            status,
+		UnlockResults:     websocket.ConvertSpeedtesterUnlockResults(result.UnlockResults),
+		UnlockSummary:     websocket.ConvertSpeedtesterUnlockSummary(result.UnlockSummary),
+	}
+	
+	if result.TestError != nil {
+		resultData.ErrorStage = result.TestError.Stage
+		resultData.ErrorCode = result.TestError.Code
+		resultData.ErrorMessage = result.TestError.Message
+	} else if result.FailureStage != "" {
+		resultData.ErrorStage = result.FailureStage
+		resultData.ErrorMessage = result.FailureReason
+	}
+	
+	t.manager.broadcast(t.id, websocket.MessageTypeTestResult, resultData)
+}
+
+// sendPhaseProgress 发送两阶段模式的阶段进度
+func (t *SpeedTestTask) sendPhaseProgress(progress speedtester.PhaseProgress) {
+	t.manager.broadcast(t.id, websocket.MessageTypePhaseProgress, websocket.PhaseProgressData{
+		Phase:              progress.Phase,
+		CurrentProxy:       progress.ProxyName,
+		ScreeningCompleted: progress.ScreeningCompleted,
+		ScreeningTotal:     progress.ScreeningTotal,
+		BandwidthCompleted: progress.BandwidthCompleted,
+		BandwidthTotal:     progress.BandwidthTotal,
+	})
+}
+
+// sendTestCancelledMessage 发送测试取消消息
+func (t *SpeedTestTask) sendTestCancelledMessage(completed, total int, duration time.Duration) {
+	cancelData := websocket.TestCancelledData{
+		Message:         "测试已被用户取消",
+		CompletedTests:  completed,
+		TotalTests:      total,
+		PartialDuration: duration.String(),
+	}
+	t.manager.broadcast(t.id, websocket.MessageTypeTestCancelled, cancelData)
+}
+
+// sendTestCompleteMessage 发送测试完成消息
+func (t *SpeedTestTask) sendTestCompleteMessage(results []*speedtester.Result, successful, failed int, duration time.Duration) {
+	var totalLatency, totalDownload, totalUpload float64
+	bestProxy := ""
+	bestDownloadSpeed := 0.0
+	
+	for _, result := range results {
+		if result.PacketLoss < 100 {
+			totalLatency += float64(result.Latency.Milliseconds())
+			totalDownload += result.DownloadSpeed / (1024 * 1024)
+			totalUpload += result.UploadSpeed / (1024 * 1024)
+			
+			downloadMbps := result.DownloadSpeed / (1024 * 1024)
+			if downloadMbps > bestDownloadSpeed {
+				bestDownloadSpeed = downloadMbps
+				bestProxy = result.ProxyName
+			}
+		}
+	}
+	
+	avgLatency := 0.0
+	avgDownload := 0.0
+	avgUpload := 0.0
+	if successful > 0 {
+		avgLatency = totalLatency / float64(successful)
+		avgDownload = totalDownload / float64(successful)
+		avgUpload = totalUpload / float64(successful)
+	}
+	
+	completeData := websocket.TestCompleteData{
+		TotalTested:       len(results),
+		SuccessfulTests:   successful,
+		FailedTests:       failed,
+		TotalDuration:     duration.String(),
+		AverageLatency:    avgLatency,
+		AverageDownload:   avgDownload,
+		AverageUpload:     avgUpload,
+		BestProxy:         bestProxy,
+		BestDownloadSpeed: bestDownloadSpeed,
+	}
+	t.manager.broadcast(t.id, websocket.MessageTypeTestComplete, completeData)
+}
+
+// sendErrorMessage 发送错误消息
+func (t *SpeedTestTask) sendErrorMessage(message, code string) {
+	t.manager.broadcast(t.id, websocket.MessageTypeError, websocket.ErrorData{
+		Message: message,
+		Code:    code,
+	})
+}
@@ -0,0 +1,97 @@
+package tasks
+
+import (
+	"time"
+
+	"github.com/zhsama/clash-speedtest/server/common"
+	"github.com/zhsama/clash-speedtest/speedtester"
+	"github.com/zhsama/clash-speedtest/unlock"
+)
+
+// NewSpeedTester 根据测试请求创建速度测试器
+func NewSpeedTester(req *common.TestRequest) *speedtester.SpeedTester {
+	return speedtester.New(&speedtester.Config{
+		ConfigPaths:       req.ConfigPaths,
+		FilterRegex:       req.FilterRegex,
+		IncludeNodes:      req.IncludeNodes,
+		ExcludeNodes:      req.ExcludeNodes,
+		ProtocolFilter:    req.ProtocolFilter,
+		ServerURL:         req.ServerURL,
+		Backend:           req.Backend,
+		BandwidthServers:  req.BandwidthServers,
+		ServerSelection:   req.ServerSelection,
+		DownloadSize:      req.DownloadSize * 1024 * 1024,
+		UploadSize:        req.UploadSize * 1024 * 1024,
+		TestDuration:      time.Duration(req.TestDuration) * time.Second,
+		SampleInterval:    time.Duration(req.SampleInterval) * time.Millisecond,
+		SaturationStreams: req.SaturationStreams,
+		SaturationStage:   time.Duration(req.SaturationStage) * time.Second,
+		Bufferbloat:       req.Bufferbloat,
+		VerifyPayload:     req.VerifyPayload,
+		Timeout:           time.Duration(req.Timeout) * time.Second,
+		Concurrent:        req.Concurrent,
+		NodeConcurrent:    req.NodeConcurrent,
+		MaxSpeedTests:     req.MaxSpeedTests,
+		Pipeline:          req.Pipeline,
+		TopN:              req.PipelineTopN,
+		MaxLatency:        time.Duration(req.MaxLatency) * time.Millisecond,
+		PingCount:         req.PingCount,
+		PingInterval:      time.Duration(req.PingInterval) * time.Millisecond,
+		LatencyURL:        req.LatencyURL,
+		LatencyProbes:     req.LatencyProbes,
+		ProbeURL:          req.ProbeURL,
+		ProbeStatus:       req.ProbeStatus,
+		MinDownloadSpeed:  req.MinDownloadSpeed * 1024 * 1024,
+		MinUploadSpeed:    req.MinUploadSpeed * 1024 * 1024,
+		FastMode:          req.FastMode,
+		RenameNodes:       req.RenameNodes,
+		RenameTemplate:    req.RenameTemplate,
+		EgressProbe:       req.EgressProbe,
+		EgressEndpoints:   req.EgressEndpoints,
+		EgressEndpointsV6: req.EgressEndpointsV6,
+		DualStack:         req.DualStack,
+		RequireIPv6:       req.RequireIPv6,
+		IPv4Target:        req.IPv4Target,
+		IPv6Target:        req.IPv6Target,
+		UDPServer:         req.UDPServer,
+		UDPPackets:        req.UDPPackets,
+		STUNServer:        req.STUNServer,
+		TestMode:          req.TestMode,
+		UnlockConfig:      newUnlockConfig(req),
+	})
+}
+
+// newUnlockConfig 根据测试请求创建解锁检测配置
+func newUnlockConfig(req *common.TestRequest) *unlock.UnlockTestConfig {
+	needsUnlock := req.TestMode == "unlock_only" || req.TestMode == "both"
+
+	if !req.UnlockEnabled && !needsUnlock {
+		return &unlock.UnlockTestConfig{
+			Enabled: false,
+		}
+	}
+
+	platforms := req.UnlockPlatforms
+	if len(platforms) == 0 {
+		platforms = []string{"Netflix", "YouTube", "Disney+", "ChatGPT", "Spotify", "Bilibili"}
+	}
+
+	concurrent := req.UnlockConcurrent
+	if concurrent <= 0 {
+		concurrent = 5
+	}
+
+	timeout := req.UnlockTimeout
+	if timeout <= 0 {
+		timeout = 10
+	}
+
+	return &unlock.UnlockTestConfig{
+		Enabled:       true,
+		Platforms:     platforms,
+		Concurrent:    concurrent,
+		Timeout:       timeout,
+		RetryOnError:  req.UnlockRetry,
+		IncludeIPInfo: true,
+	}
+}
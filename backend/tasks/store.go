@@ -0,0 +1,222 @@
+package tasks
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/zhsama/clash-speedtest/logger"
+	"github.com/zhsama/clash-speedtest/speedtester"
+)
+
+// Store 任务持久化存储接口
+type Store interface {
+	// SaveTask 保存任务元数据（不含结果），重复保存时以最新一次为准
+	SaveTask(task *TaskResult) error
+	// AppendResult 追加单个节点的测试结果
+	AppendResult(taskID string, result *speedtester.Result) error
+	// LoadTasks 加载所有任务及其结果
+	LoadTasks() ([]*TaskResult, error)
+	// DeleteTask 删除任务及其结果
+	DeleteTask(taskID string) error
+}
+
+// storeEntry JSON-lines 文件中的单行记录
+type storeEntry struct {
+	Kind   string              `json:"kind"` // "task" or "result"
+	Task   *TaskResult         `json:"task,omitempty"`
+	Result *speedtester.Result `json:"result,omitempty"`
+}
+
+const (
+	storeEntryTask   = "task"
+	storeEntryResult = "result"
+)
+
+// FileStore 基于 JSON-lines 文件的任务存储，每个任务对应目录下的一个文件
+type FileStore struct {
+	dir   string
+	mutex sync.Mutex
+	// removed 已删除的任务，被取消的任务在执行器退出前仍可能写入，这些写入会被丢弃以免重新创建文件
+	removed map[string]struct{}
+}
+
+// NewFileStore 创建文件存储，目录不存在时自动创建
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create task store directory: %w", err)
+	}
+	return &FileStore{dir: dir, removed: make(map[string]struct{})}, nil
+}
+
+// SaveTask 追加任务元数据记录
+func (s *FileStore) SaveTask(task *TaskResult) error {
+	meta := *task
+	meta.Results = nil
+	return s.append(task.TaskID, &storeEntry{Kind: storeEntryTask, Task: &meta})
+}
+
+// AppendResult 追加测试结果记录
+func (s *FileStore) AppendResult(taskID string, result *speedtester.Result) error {
+	return s.append(taskID, &storeEntry{Kind: storeEntryResult, Result: result})
+}
+
+// LoadTasks 读取目录下所有任务文件，并压缩含有过期记录的文件
+func (s *FileStore) LoadTasks() ([]*TaskResult, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task files: %w", err)
+	}
+
+	tasks := make([]*TaskResult, 0, len(files))
+	for _, file := range files {
+		task, stale, err := s.loadFile(file)
+		if err != nil {
+			logger.LogError("Failed to load stored task", err, slog.String("file", file))
+			continue
+		}
+		if task == nil {
+			continue
+		}
+		if stale {
+			if err := s.compact(file, task); err != nil {
+				logger.LogError("Failed to compact stored task", err, slog.String("file", file))
+			}
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+// DeleteTask 删除任务文件
+func (s *FileStore) DeleteTask(taskID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.removed[taskID] = struct{}{}
+	if err := os.Remove(s.taskPath(taskID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete task file: %w", err)
+	}
+	return nil
+}
+
+// append 向任务文件追加一行记录
+func (s *FileStore) append(taskID string, entry *storeEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s entry: %w", entry.Kind, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.removed[taskID]; ok {
+		return nil
+	}
+
+	file, err := os.OpenFile(s.taskPath(taskID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open task file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write task file: %w", err)
+	}
+	return nil
+}
+
+// loadFile 回放单个任务文件，元数据以最后一条为准；
+// 文件含有被覆盖的元数据或损坏的行时 stale 为 true
+func (s *FileStore) loadFile(path string) (task *TaskResult, stale bool, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	var results []*speedtester.Result
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		var entry storeEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// 进程异常退出时最后一行可能不完整
+			logger.Logger.Warn("Skipping malformed task store entry",
+				slog.String("file", path),
+				slog.Int("line", lineNo),
+				slog.String("error", err.Error()),
+			)
+			stale = true
+			continue
+		}
+
+		switch entry.Kind {
+		case storeEntryTask:
+			stale = stale || task != nil
+			task = entry.Task
+		case storeEntryResult:
+			if entry.Result != nil {
+				results = append(results, entry.Result)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, err
+	}
+
+	if task != nil {
+		task.Results = results
+	}
+	return task, stale, nil
+}
+
+// compact 将任务文件重写为一条元数据记录加全部结果，写入临时文件后替换原文件
+func (s *FileStore) compact(path string, task *TaskResult) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted task file: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	meta := *task
+	meta.Results = nil
+	err = encoder.Encode(&storeEntry{Kind: storeEntryTask, Task: &meta})
+	for _, result := range task.Results {
+		if err != nil {
+			break
+		}
+		err = encoder.Encode(&storeEntry{Kind: storeEntryResult, Result: result})
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write compacted task file: %w", err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// taskPath 返回任务文件路径
+func (s *FileStore) taskPath(taskID string) string {
+	// 任务 ID 由管理器生成，这里仍然过滤路径分隔符以防万一
+	safeID := strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(taskID)
+	return filepath.Join(s.dir, safeID+".jsonl")
+}
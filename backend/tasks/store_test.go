@@ -0,0 +1,264 @@
+package tasks
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhsama/clash-speedtest/speedtester"
+)
+
+// newTestStore 在临时目录中创建文件存储
+func newTestStore(t *testing.T) *FileStore {
+	t.Helper()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// countLines 返回任务文件的行数，文件不存在时返回 -1
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return -1
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lines := 0
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		lines++
+	}
+	return lines
+}
+
+func TestFileStore(t *testing.T) {
+	type stored struct {
+		status  TaskStatus
+		results []string
+	}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s *FileStore)
+		want map[string]stored
+	}{
+		{
+			name: "save and load",
+			run: func(t *testing.T, s *FileStore) {
+				s.SaveTask(&TaskResult{TaskID: "a", Status: TaskStatusRunning})
+				s.AppendResult("a", &speedtester.Result{ProxyName: "p1"})
+				s.AppendResult("a", &speedtester.Result{ProxyName: "p2"})
+			},
+			want: map[string]stored{"a": {TaskStatusRunning, []string{"p1", "p2"}}},
+		},
+		{
+			name: "latest metadata wins",
+			run: func(t *testing.T, s *FileStore) {
+				s.SaveTask(&TaskResult{TaskID: "a", Status: TaskStatusRunning})
+				s.AppendResult("a", &speedtester.Result{ProxyName: "p1"})
+				s.SaveTask(&TaskResult{TaskID: "a", Status: TaskStatusCompleted})
+			},
+			want: map[string]stored{"a": {TaskStatusCompleted, []string{"p1"}}},
+		},
+		{
+			name: "results are not stored with metadata",
+			run: func(t *testing.T, s *FileStore) {
+				s.SaveTask(&TaskResult{TaskID: "a", Status: TaskStatusCompleted, Results: []*speedtester.Result{{ProxyName: "p1"}}})
+			},
+			want: map[string]stored{"a": {TaskStatusCompleted, nil}},
+		},
+		{
+			name: "several tasks",
+			run: func(t *testing.T, s *FileStore) {
+				s.SaveTask(&TaskResult{TaskID: "a", Status: TaskStatusCompleted})
+				s.SaveTask(&TaskResult{TaskID: "b", Status: TaskStatusFailed})
+				s.AppendResult("b", &speedtester.Result{ProxyName: "p1"})
+			},
+			want: map[string]stored{
+				"a": {TaskStatusCompleted, nil},
+				"b": {TaskStatusFailed, []string{"p1"}},
+			},
+		},
+		{
+			name: "results without metadata are ignored",
+			run: func(t *testing.T, s *FileStore) {
+				s.AppendResult("a", &speedtester.Result{ProxyName: "p1"})
+			},
+			want: map[string]stored{},
+		},
+		{
+			name: "delete",
+			run: func(t *testing.T, s *FileStore) {
+				s.SaveTask(&TaskResult{TaskID: "a", Status: TaskStatusCompleted})
+				s.SaveTask(&TaskResult{TaskID: "b", Status: TaskStatusCompleted})
+				if err := s.DeleteTask("a"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string]stored{"b": {TaskStatusCompleted, nil}},
+		},
+		{
+			name: "delete missing task",
+			run: func(t *testing.T, s *FileStore) {
+				if err := s.DeleteTask("missing"); err != nil {
+					t.Fatalf("DeleteTask() error: %v", err)
+				}
+			},
+			want: map[string]stored{},
+		},
+		{
+			// 被取消的执行器在删除后写入不应重新创建文件
+			name: "writes after delete are dropped",
+			run: func(t *testing.T, s *FileStore) {
+				s.SaveTask(&TaskResult{TaskID: "a", Status: TaskStatusRunning})
+				s.DeleteTask("a")
+				if err := s.AppendResult("a", &speedtester.Result{ProxyName: "p1"}); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.SaveTask(&TaskResult{TaskID: "a", Status: TaskStatusCancelled}); err != nil {
+					t.Fatal(err)
+				}
+				if lines := countLines(t, s.taskPath("a")); lines != -1 {
+					t.Errorf("task file recreated with %d lines", lines)
+				}
+			},
+			want: map[string]stored{},
+		},
+		{
+			// 进程异常退出时最后一行可能不完整
+			name: "truncated last line",
+			run: func(t *testing.T, s *FileStore) {
+				s.SaveTask(&TaskResult{TaskID: "a", Status: TaskStatusRunning})
+				s.AppendResult("a", &speedtester.Result{ProxyName: "p1"})
+				file, err := os.OpenFile(s.taskPath("a"), os.O_APPEND|os.O_WRONLY, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				file.WriteString(`{"kind":"result","result":{"proxy_na`)
+				file.Close()
+			},
+			want: map[string]stored{"a": {TaskStatusRunning, []string{"p1"}}},
+		},
+		{
+			name: "task ID with path separators stays in the store directory",
+			run: func(t *testing.T, s *FileStore) {
+				s.SaveTask(&TaskResult{TaskID: "../a/b", Status: TaskStatusCompleted})
+				if dir := filepath.Dir(s.taskPath("../a/b")); dir != s.dir {
+					t.Errorf("task file in %s, want %s", dir, s.dir)
+				}
+			},
+			want: map[string]stored{"../a/b": {TaskStatusCompleted, nil}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			tt.run(t, store)
+
+			// 使用新的存储实例读取，模拟服务重启
+			reopened, err := NewFileStore(store.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tasks, err := reopened.LoadTasks()
+			if err != nil {
+				t.Fatalf("LoadTasks() error: %v", err)
+			}
+
+			if len(tasks) != len(tt.want) {
+				t.Fatalf("LoadTasks() returned %d tasks, want %d", len(tasks), len(tt.want))
+			}
+			for _, task := range tasks {
+				want, ok := tt.want[task.TaskID]
+				if !ok {
+					t.Errorf("unexpected task %q", task.TaskID)
+					continue
+				}
+				if task.Status != want.status {
+					t.Errorf("task %q status = %q, want %q", task.TaskID, task.Status, want.status)
+				}
+				var names []string
+				for _, result := range task.Results {
+					names = append(names, result.ProxyName)
+				}
+				if len(names) != len(want.results) {
+					t.Errorf("task %q results = %v, want %v", task.TaskID, names, want.results)
+					continue
+				}
+				for i := range names {
+					if names[i] != want.results[i] {
+						t.Errorf("task %q results = %v, want %v", task.TaskID, names, want.results)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFileStoreCompact(t *testing.T) {
+	tests := []struct {
+		name      string
+		saves     int
+		results   int
+		truncated bool
+		wantLines int
+	}{
+		{"single metadata entry untouched", 1, 2, false, 3},
+		{"superseded metadata dropped", 3, 2, false, 3},
+		{"malformed line dropped", 1, 1, true, 2},
+		{"metadata only", 4, 0, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			for i := range tt.saves {
+				status := TaskStatusRunning
+				if i == tt.saves-1 {
+					status = TaskStatusCompleted
+				}
+				if err := store.SaveTask(&TaskResult{TaskID: "a", Status: status}); err != nil {
+					t.Fatal(err)
+				}
+				if i == 0 {
+					for range tt.results {
+						store.AppendResult("a", &speedtester.Result{ProxyName: "p"})
+					}
+				}
+			}
+			if tt.truncated {
+				file, _ := os.OpenFile(store.taskPath("a"), os.O_APPEND|os.O_WRONLY, 0644)
+				file.WriteString("{\"kind\":\n")
+				file.Close()
+			}
+
+			if _, err := store.LoadTasks(); err != nil {
+				t.Fatal(err)
+			}
+			if lines := countLines(t, store.taskPath("a")); lines != tt.wantLines {
+				t.Errorf("task file has %d lines after load, want %d", lines, tt.wantLines)
+			}
+
+			// 压缩后的文件内容与压缩前一致
+			tasks, err := store.LoadTasks()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tasks) != 1 || tasks[0].Status != TaskStatusCompleted || len(tasks[0].Results) != tt.results {
+				t.Fatalf("reloaded %+v, want one completed task with %d results", tasks, tt.results)
+			}
+			if matches, _ := filepath.Glob(filepath.Join(store.dir, "*.tmp")); len(matches) > 0 {
+				t.Errorf("temporary files left behind: %v", matches)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/zhsama/clash-speedtest/server/common"
+	"github.com/zhsama/clash-speedtest/speedtester"
+)
+
+// StoredTask 从持久化存储恢复的只读任务
+type StoredTask struct {
+	record *TaskResult
+	done   chan struct{}
+}
+
+// newStoredTask 根据存储记录创建只读任务
+func newStoredTask(record *TaskResult) *StoredTask {
+	done := make(chan struct{})
+	close(done)
+	return &StoredTask{
+		record: record,
+		done:   done,
+	}
+}
+
+// ID 返回任务 ID
+func (t *StoredTask) ID() string {
+	return t.record.TaskID
+}
+
+// Type 返回任务类型
+func (t *StoredTask) Type() TaskType {
+	return t.record.Type
+}
+
+// Status 返回任务状态
+func (t *StoredTask) Status() TaskStatus {
+	return t.record.Status
+}
+
+// Config 返回任务配置
+func (t *StoredTask) Config() *common.TestRequest {
+	return t.record.Config
+}
+
+// Context 返回任务上下文
+func (t *StoredTask) Context() context.Context {
+	return context.Background()
+}
+
+// Start 已恢复的任务不能再次启动
+func (t *StoredTask) Start() error {
+	return fmt.Errorf("task is not in pending status")
+}
+
+// Cancel 已恢复的任务不在运行
+func (t *StoredTask) Cancel() error {
+	return fmt.Errorf("task is not running")
+}
+
+// Results 返回任务结果
+func (t *StoredTask) Results() []*speedtester.Result {
+	return t.record.Results
+}
+
+// Progress 返回任务进度
+func (t *StoredTask) Progress() *TaskProgress {
+	progress := TaskProgress{StartTime: t.record.StartTime}
+	if t.record.Progress != nil {
+		progress = *t.record.Progress
+	}
+	return &progress
+}
+
+// Error 返回任务错误
+func (t *StoredTask) Error() error {
+	if t.record.Error == "" {
+		return nil
+	}
+	return errors.New(t.record.Error)
+}
+
+// Done 返回已关闭的通道
+func (t *StoredTask) Done() <-chan struct{} {
+	return t.done
+}
+
+// Snapshot 返回存储的任务记录
+func (t *StoredTask) Snapshot() *TaskResult {
+	snapshot := *t.record
+	snapshot.Progress = t.Progress()
+	return &snapshot
+}
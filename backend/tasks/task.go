@@ -12,11 +12,12 @@ import (
 type TaskStatus string
 
 const (
-	TaskStatusPending   TaskStatus = "pending"
-	TaskStatusRunning   TaskStatus = "running"
-	TaskStatusCompleted TaskStatus = "completed"
-	TaskStatusCancelled TaskStatus = "cancelled"
-	TaskStatusFailed    TaskStatus = "failed"
+	TaskStatusPending     TaskStatus = "pending"
+	TaskStatusRunning     TaskStatus = "running"
+	TaskStatusCompleted   TaskStatus = "completed"
+	TaskStatusCancelled   TaskStatus = "cancelled"
+	TaskStatusFailed      TaskStatus = "failed"
+	TaskStatusInterrupted TaskStatus = "interrupted" // 服务重启时仍未结束的任务
 )
 
 // TaskType 任务类型枚举
@@ -40,6 +41,8 @@ type Task interface {
 	Results() []*speedtester.Result
 	Progress() *TaskProgress
 	Error() error
+	Done() <-chan struct{}
+	Snapshot() *TaskResult
 }
 
 // TaskProgress 任务进度信息
@@ -51,17 +54,25 @@ type TaskProgress struct {
 	Status          string  `json:"status"`
 	StartTime       time.Time `json:"start_time"`
 	Duration        time.Duration `json:"duration"`
+	// 两阶段模式下的阶段进度
+	Phase              string `json:"phase,omitempty"`
+	ScreeningCompleted int    `json:"screening_completed,omitempty"`
+	ScreeningTotal     int    `json:"screening_total,omitempty"`
+	BandwidthCompleted int    `json:"bandwidth_completed,omitempty"`
+	BandwidthTotal     int    `json:"bandwidth_total,omitempty"`
 }
 
 // TaskResult 任务结果
 type TaskResult struct {
 	TaskID        string                `json:"task_id"`
+	Type          TaskType              `json:"type"`
 	Status        TaskStatus            `json:"status"`
-	Results       []*speedtester.Result `json:"results"`
-	Error         error                 `json:"error,omitempty"`
+	Config        *common.TestRequest   `json:"config"`
+	Results       []*speedtester.Result `json:"results,omitempty"`
+	Error         string                `json:"error,omitempty"`
 	Progress      *TaskProgress         `json:"progress"`
 	StartTime     time.Time             `json:"start_time"`
-	CompleteTime  time.Time             `json:"complete_time"`
+	CompleteTime  time.Time             `json:"complete_time,omitempty"`
 	Duration      time.Duration         `json:"duration"`
 	SuccessCount  int                   `json:"success_count"`
 	FailedCount   int                   `json:"failed_count"`
@@ -87,7 +98,7 @@ const (
 
 // TaskOptions 任务选项
 type TaskOptions struct {
-	Timeout    time.Duration
+	Timeout    time.Duration // 0 表示不限制
 	RetryCount int
 	Async      bool
 }
@@ -95,7 +106,7 @@ type TaskOptions struct {
 // DefaultTaskOptions 默认任务选项
 func DefaultTaskOptions() *TaskOptions {
 	return &TaskOptions{
-		Timeout:    10 * time.Minute,
+		Timeout:    0,
 		RetryCount: 0,
 		Async:      true,
 	}
@@ -0,0 +1,317 @@
+package export
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zhsama/clash-speedtest/utils/geo"
+)
+
+// Proxy group member modes
+const (
+	MembersNodes  = "nodes"  // all matching nodes (default)
+	MembersGroups = "groups" // other generated groups
+	MembersAll    = "all"    // other generated groups followed by nodes
+	MembersNone   = "none"   // only the static proxies
+)
+
+// Default health check settings for url-test, fallback and load-balance groups
+const (
+	DefaultHealthCheckURL      = "http://www.gstatic.com/generate_204"
+	DefaultHealthCheckInterval = 300
+)
+
+// ipCheckPlatforms are the detector names reporting the exit country rather than an unlock
+var ipCheckPlatforms = []string{"IP Check", "IP Location Check"}
+
+// ProxyGroupTemplate describes proxy groups to generate in Clash output.
+//
+// Per-country group names support {country}, {code} and {flag}; per-platform
+// group names support {platform}, {region} and {flag}. Separators left at either
+// end of a name by an empty {region} are trimmed, so "{platform}-{region}"
+// yields both "Netflix-JP" and "ChatGPT".
+type ProxyGroupTemplate struct {
+	Name        string   `json:"name" yaml:"name"`                                     // Group name or naming template
+	Type        string   `json:"type" yaml:"type"`                                     // select, url-test, fallback, load-balance
+	Members     string   `json:"members,omitempty" yaml:"members,omitempty"`           // nodes, groups, all, none
+	PerCountry  bool     `json:"per_country,omitempty" yaml:"per_country,omitempty"`   // One group per node country
+	PerPlatform bool     `json:"per_platform,omitempty" yaml:"per_platform,omitempty"` // One group per unlocked platform (and region)
+	Platforms   []string `json:"platforms,omitempty" yaml:"platforms,omitempty"`       // Limit per-platform groups to these platforms
+	Proxies     []string `json:"proxies,omitempty" yaml:"proxies,omitempty"`           // Static members appended to the group, e.g. DIRECT
+	URL         string   `json:"url,omitempty" yaml:"url,omitempty"`                   // Health check URL
+	Interval    int      `json:"interval,omitempty" yaml:"interval,omitempty"`         // Health check interval in seconds
+	Tolerance   int      `json:"tolerance,omitempty" yaml:"tolerance,omitempty"`       // url-test tolerance in ms
+	Strategy    string   `json:"strategy,omitempty" yaml:"strategy,omitempty"`         // load-balance strategy
+}
+
+// clashNode is a proxy written to the Clash config
+type clashNode struct {
+	name   string
+	result ExportableResult
+}
+
+// clashGroup is a concrete proxy group expanded from a template
+type clashGroup struct {
+	name     string
+	template ProxyGroupTemplate
+	nodes    []string
+}
+
+// DefaultProxyGroups returns the proxy groups used when no templates are given
+func DefaultProxyGroups() []ProxyGroupTemplate {
+	return []ProxyGroupTemplate{
+		{Name: "🚀 节点选择", Type: "select", Members: MembersGroups},
+		{Name: "♻️ 自动选择", Type: "url-test", URL: DefaultHealthCheckURL, Interval: DefaultHealthCheckInterval},
+		{Name: "{flag} {country}", Type: "url-test", PerCountry: true},
+		{Name: "{platform}-{region}", Type: "url-test", PerPlatform: true},
+		{Name: "🎯 全球直连", Type: "select", Members: MembersNone, Proxies: []string{"DIRECT"}},
+	}
+}
+
+// ValidateProxyGroups validates proxy group templates
+func ValidateProxyGroups(templates []ProxyGroupTemplate) error {
+	for _, template := range templates {
+		if template.Name == "" {
+			return fmt.Errorf("proxy group name is required")
+		}
+		switch template.Type {
+		case "select", "url-test", "fallback", "load-balance":
+		default:
+			return fmt.Errorf("proxy group %q: unsupported type %q", template.Name, template.Type)
+		}
+		switch template.Members {
+		case "", MembersNodes, MembersGroups, MembersAll, MembersNone:
+		default:
+			return fmt.Errorf("proxy group %q: unsupported members %q", template.Name, template.Members)
+		}
+		if template.PerCountry && template.PerPlatform {
+			return fmt.Errorf("proxy group %q: per_country and per_platform are mutually exclusive", template.Name)
+		}
+		if len(template.Platforms) > 0 && !template.PerPlatform {
+			return fmt.Errorf("proxy group %q: platforms requires per_platform", template.Name)
+		}
+	}
+	return nil
+}
+
+// buildProxyGroups expands templates into Clash proxy groups
+func buildProxyGroups(templates []ProxyGroupTemplate, nodes []clashNode) []map[string]any {
+	if len(templates) == 0 {
+		templates = DefaultProxyGroups()
+	}
+
+	var groups []*clashGroup
+	seen := make(map[string]bool)
+	add := func(expanded ...*clashGroup) {
+		// Clash 要求组名唯一，重名时保留先出现的组
+		for _, group := range expanded {
+			if !seen[group.name] {
+				seen[group.name] = true
+				groups = append(groups, group)
+			}
+		}
+	}
+
+	for _, template := range templates {
+		switch {
+		case template.PerCountry:
+			add(expandCountryGroups(template, nodes)...)
+		case template.PerPlatform:
+			add(expandPlatformGroups(template, nodes)...)
+		default:
+			group := &clashGroup{name: template.Name, template: template}
+			for _, node := range nodes {
+				group.nodes = append(group.nodes, node.name)
+			}
+			add(group)
+		}
+	}
+
+	proxyGroups := make([]map[string]any, 0, len(groups))
+	for _, group := range groups {
+		proxyGroups = append(proxyGroups, group.render(groups))
+	}
+	return proxyGroups
+}
+
+// expandCountryGroups creates one group per country, ordered by country code
+func expandCountryGroups(template ProxyGroupTemplate, nodes []clashNode) []*clashGroup {
+	byCode := make(map[string]*clashGroup)
+	var codes []string
+
+	for _, node := range nodes {
+		code, country := nodeCountry(node.result)
+		if code == "" {
+			continue
+		}
+
+		group, exists := byCode[code]
+		if !exists {
+			group = &clashGroup{
+				name: strings.NewReplacer(
+					"{country}", country,
+					"{code}", code,
+					"{flag}", geo.GetFlagEmoji(code),
+				).Replace(template.Name),
+				template: template,
+			}
+			byCode[code] = group
+			codes = append(codes, code)
+		}
+		group.nodes = append(group.nodes, node.name)
+	}
+
+	sort.Strings(codes)
+	groups := make([]*clashGroup, 0, len(codes))
+	for _, code := range codes {
+		groups = append(groups, byCode[code])
+	}
+	return groups
+}
+
+// expandPlatformGroups creates one group per unlocked platform, ordered by name.
+// Nodes are split by unlock region when the name template contains {region}.
+func expandPlatformGroups(template ProxyGroupTemplate, nodes []clashNode) []*clashGroup {
+	byRegion := strings.Contains(template.Name, "{region}")
+	byName := make(map[string]*clashGroup)
+	var names []string
+
+	for _, node := range nodes {
+		for _, unlock := range node.result.UnlockResults {
+			if !unlock.Supported || isIPCheck(unlock.Platform) || !containsFold(template.Platforms, unlock.Platform) {
+				continue
+			}
+
+			region := ""
+			if byRegion {
+				region = strings.ToUpper(strings.TrimSpace(unlock.Region))
+			}
+			name := strings.Trim(strings.NewReplacer(
+				"{platform}", unlock.Platform,
+				"{region}", region,
+				"{flag}", geo.GetFlagEmoji(region),
+			).Replace(template.Name), " -_|/")
+
+			group, exists := byName[name]
+			if !exists {
+				group = &clashGroup{name: name, template: template}
+				byName[name] = group
+				names = append(names, name)
+			}
+			group.nodes = append(group.nodes, node.name)
+		}
+	}
+
+	sort.Strings(names)
+	groups := make([]*clashGroup, 0, len(names))
+	for _, name := range names {
+		groups = append(groups, byName[name])
+	}
+	return groups
+}
+
+// nodeCountry returns the country code and name of a node, preferring the geo
+// lookup and falling back to the region reported by the IP Check detector
+func nodeCountry(result ExportableResult) (string, string) {
+	if code := strings.ToUpper(result.CountryCode); code != "" {
+		country := result.Country
+		if country == "" {
+			country = code
+		}
+		return code, country
+	}
+
+	for _, unlock := range result.UnlockResults {
+		if !isIPCheck(unlock.Platform) {
+			continue
+		}
+		region := strings.TrimSpace(unlock.Region)
+		if region == "" || region == "N/A" {
+			return "", ""
+		}
+		if len(region) == 2 {
+			region = strings.ToUpper(region)
+		}
+		return region, region
+	}
+	return "", ""
+}
+
+// isIPCheck reports whether the platform is the IP Check detector
+func isIPCheck(platform string) bool {
+	return containsFold(ipCheckPlatforms, platform)
+}
+
+// containsFold reports whether value is in list, ignoring case; an empty list matches everything
+func containsFold(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// render converts the group to its Clash representation
+func (g *clashGroup) render(all []*clashGroup) map[string]any {
+	var proxies []string
+
+	members := g.template.Members
+	if members == "" {
+		members = MembersNodes
+	}
+
+	if members == MembersGroups || members == MembersAll {
+		// 只引用直接包含节点的组，避免组之间循环引用
+		for _, other := range all {
+			if other == g || other.includesGroups() {
+				continue
+			}
+			proxies = append(proxies, other.name)
+		}
+	}
+	if members == MembersNodes || members == MembersAll {
+		proxies = append(proxies, g.nodes...)
+	}
+	proxies = append(proxies, g.template.Proxies...)
+
+	// Clash 不接受空的代理组
+	if len(proxies) == 0 {
+		proxies = []string{"DIRECT"}
+	}
+
+	group := map[string]any{
+		"name":    g.name,
+		"type":    g.template.Type,
+		"proxies": proxies,
+	}
+
+	if g.template.Type != "select" {
+		url, interval := g.template.URL, g.template.Interval
+		if url == "" {
+			url = DefaultHealthCheckURL
+		}
+		if interval <= 0 {
+			interval = DefaultHealthCheckInterval
+		}
+		group["url"] = url
+		group["interval"] = interval
+	}
+	if g.template.Type == "url-test" && g.template.Tolerance > 0 {
+		group["tolerance"] = g.template.Tolerance
+	}
+	if g.template.Type == "load-balance" && g.template.Strategy != "" {
+		group["strategy"] = g.template.Strategy
+	}
+
+	return group
+}
+
+// includesGroups reports whether the group references other groups
+func (g *clashGroup) includesGroups() bool {
+	return g.template.Members == MembersGroups || g.template.Members == MembersAll
+}
@@ -0,0 +1,156 @@
+package export
+
+import (
+	"time"
+
+	"github.com/zhsama/clash-speedtest/speedtester"
+	"github.com/zhsama/clash-speedtest/utils/geo"
+)
+
+// FromSpeedtestResult converts a speed test result into an exportable result.
+// location is optional and fills the geo fields when available; without it the
+// exit location resolved during node renaming is used.
+func FromSpeedtestResult(result *speedtester.Result, status string, testTime time.Time, location *geo.GeoLocation) ExportableResult {
+	if location == nil {
+		location = result.ExitLocation
+	}
+
+	exportable := ExportableResult{
+		ProxyName:     result.ProxyName,
+		ProxyType:     result.ProxyType,
+		ProxyServer:   result.ProxyIP,
+		ProxyPort:     proxyPort(result.ProxyConfig),
+		EntryIP:       result.EntryIP,
+		EgressIPv4:    result.EgressIPv4,
+		EgressIPv6:    result.EgressIPv6,
+		Relayed:       result.Relayed,
+		ASN:           result.ASN,
+		ASOrg:         result.ASOrg,
+		ASNType:       result.ASNType,
+		IPv4OK:        result.IPv4OK,
+		IPv6OK:        result.IPv6OK,
+		IPv4Latency:   result.IPv4Latency.Milliseconds(),
+		IPv6Latency:   result.IPv6Latency.Milliseconds(),
+		UDPSupported:  result.UDPSupported,
+		UDPLatency:    result.UDPLatency.Milliseconds(),
+		UDPPacketLoss: result.UDPPacketLoss,
+		NATType:       result.NATType,
+		NATMapping:    result.NATMapping,
+		NATFiltering:  result.NATFiltering,
+		Latency:       result.Latency.Milliseconds(),
+		Jitter:        result.Jitter.Milliseconds(),
+		LatencyMin:    result.LatencyMin.Milliseconds(),
+		LatencyMedian: result.LatencyMedian.Milliseconds(),
+		LatencyP90:    result.LatencyP90.Milliseconds(),
+		LatencyMax:    result.LatencyMax.Milliseconds(),
+		ArrivalJitter: result.ArrivalJitter.Milliseconds(),
+		PacketLoss:    result.PacketLoss,
+		DialTime:      result.DialTime.Milliseconds(),
+		ConnectTime:   result.ProxyConnectTime.Milliseconds(),
+		HandshakeTime: result.ProxyHandshakeTime.Milliseconds(),
+		TLSTime:       result.TLSTime.Milliseconds(),
+		WriteTime:     result.WriteTime.Milliseconds(),
+		TTFB:          result.TTFB.Milliseconds(),
+		SpeedServer:   result.BandwidthServer,
+		DownloadSpeed: result.DownloadSpeed / (1024 * 1024),
+		DownloadPeak:  result.DownloadPeak / (1024 * 1024),
+		UploadSpeed:   result.UploadSpeed / (1024 * 1024),
+		UploadPeak:    result.UploadPeak / (1024 * 1024),
+		SingleStream:  result.SingleStreamSpeed / (1024 * 1024),
+		MultiStream:   result.MultiStreamSpeed / (1024 * 1024),
+		KneeStreams:   result.SaturationKnee,
+		StreamLimited: result.PerStreamLimited,
+		IdleLatency:   result.IdleLatency.Milliseconds(),
+		LoadedDown:    result.LoadedDownloadLatency.Milliseconds(),
+		LoadedUp:      result.LoadedUploadLatency.Milliseconds(),
+		Bufferbloat:   result.BufferbloatGrade,
+		Verified:      result.PayloadVerified,
+		Integrity:     result.IntegrityError,
+		TestTime:      testTime,
+		Status:        status,
+		ErrorMessage:  result.FailureReason,
+		ProxyConfig:   result.ProxyConfig,
+	}
+
+	exportable.DownloadSeries = toMBps(result.DownloadSeries)
+	exportable.UploadSeries = toMBps(result.UploadSeries)
+
+	for _, server := range result.ServerResults {
+		exportable.ServerResults = append(exportable.ServerResults, ServerResult{
+			Server:        server.Server,
+			Latency:       server.Latency.Milliseconds(),
+			PacketLoss:    server.PacketLoss,
+			DownloadSpeed: server.DownloadSpeed / (1024 * 1024),
+			UploadSpeed:   server.UploadSpeed / (1024 * 1024),
+			Error:         server.Error,
+		})
+	}
+
+	if latency, ok := result.ProbeLatency(speedtester.ProbeHTTP); ok {
+		exportable.HTTPProbe = latency.Milliseconds()
+	}
+	if latency, ok := result.ProbeLatency(speedtester.ProbeTCP); ok {
+		exportable.TCPProbe = latency.Milliseconds()
+	}
+	if latency, ok := result.ProbeLatency(speedtester.ProbeTLS); ok {
+		exportable.TLSProbe = latency.Milliseconds()
+	}
+
+	if exportable.ErrorMessage == "" && result.TestError != nil {
+		exportable.ErrorMessage = result.TestError.Message
+	}
+
+	if location != nil {
+		exportable.Country = location.Country
+		exportable.CountryCode = location.CountryCode
+		exportable.City = location.City
+		exportable.ISP = location.ISP
+
+		// Without an egress probe the ASN comes from the given location
+		if exportable.ASN == 0 {
+			exportable.ASN, exportable.ASOrg = location.ASInfo()
+			if exportable.ASN != 0 {
+				exportable.ASNType = geo.ClassifyASN(exportable.ASN, exportable.ASOrg)
+			}
+		}
+	}
+
+	for _, unlockResult := range result.UnlockResults {
+		exportable.UnlockResults = append(exportable.UnlockResults, UnlockResult{
+			Platform:     unlockResult.Platform,
+			Supported:    unlockResult.Supported,
+			Region:       unlockResult.Region,
+			ErrorMessage: unlockResult.ErrorMessage,
+		})
+		if unlockResult.Supported {
+			exportable.UnlockedPlatforms = append(exportable.UnlockedPlatforms, unlockResult.Platform)
+		}
+	}
+
+	return exportable
+}
+
+// proxyPort extracts the port from a proxy configuration
+func proxyPort(config map[string]any) int {
+	switch port := config["port"].(type) {
+	case int:
+		return port
+	case float64:
+		return int(port)
+	case uint16:
+		return int(port)
+	}
+	return 0
+}
+
+// toMBps converts a throughput series from bytes/s to MB/s
+func toMBps(series []float64) []float64 {
+	if len(series) == 0 {
+		return nil
+	}
+	converted := make([]float64, len(series))
+	for i, sample := range series {
+		converted[i] = sample / (1024 * 1024)
+	}
+	return converted
+}
@@ -4,11 +4,15 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/zhsama/clash-speedtest/utils/geo"
 	"github.com/zhsama/clash-speedtest/utils/stats"
 	"gopkg.in/yaml.v3"
 )
@@ -32,8 +36,18 @@ type ExportOptions struct {
 	TopN            int          `json:"top_n"`             // Export only top N results (0 = all)
 	MinLatency      int          `json:"min_latency_ms"`    // Filter by minimum latency
 	MaxLatency      int          `json:"max_latency_ms"`    // Filter by maximum latency
-	MinDownload     float64      `json:"min_download_mbps"` // Filter by minimum download speed
-	MinUpload       float64      `json:"min_upload_mbps"`   // Filter by minimum upload speed
+	MinDownload     float64      `json:"min_download_mb_s"` // Filter by minimum download speed in MB/s
+	MinUpload       float64      `json:"min_upload_mb_s"`   // Filter by minimum upload speed in MB/s
+
+	// Filter by unlocked platforms; a result must unlock all of them
+	RequiredPlatforms []string `json:"required_platforms,omitempty"`
+
+	// Filter by exit ASN category (residential, datacenter, mobile, education); a result must match one of them
+	ASNTypes []string `json:"asn_types,omitempty"`
+
+	// Clash output options
+	ProxyGroups    []ProxyGroupTemplate `json:"proxy_groups,omitempty"`     // Proxy group templates (default groups when empty)
+	KeepProxyNames bool                 `json:"keep_proxy_names,omitempty"` // Do not append speed information to proxy names
 }
 
 // ExportableResult represents a result that can be exported
@@ -46,19 +60,92 @@ type ExportableResult struct {
 	CountryCode   string    `json:"country_code" csv:"Country Code"`
 	City          string    `json:"city" csv:"City"`
 	ISP           string    `json:"isp" csv:"ISP"`
+	EntryIP       string    `json:"entry_ip,omitempty" csv:"Entry IP"`
+	EgressIPv4    string    `json:"egress_ipv4,omitempty" csv:"Egress IPv4"`
+	EgressIPv6    string    `json:"egress_ipv6,omitempty" csv:"Egress IPv6"`
+	Relayed       bool      `json:"relayed" csv:"Relayed"`
+	ASN           uint      `json:"asn,omitempty" csv:"ASN"`
+	ASOrg         string    `json:"as_org,omitempty" csv:"AS Org"`
+	ASNType       string    `json:"asn_type,omitempty" csv:"ASN Type"`
+	IPv4OK        bool      `json:"ipv4_ok" csv:"IPv4"`
+	IPv6OK        bool      `json:"ipv6_ok" csv:"IPv6"`
+	IPv4Latency   int64     `json:"ipv4_latency_ms,omitempty" csv:"IPv4 Latency (ms)"`
+	IPv6Latency   int64     `json:"ipv6_latency_ms,omitempty" csv:"IPv6 Latency (ms)"`
+	UDPSupported  bool      `json:"udp_supported" csv:"UDP"`
+	UDPLatency    int64     `json:"udp_latency_ms,omitempty" csv:"UDP Latency (ms)"`
+	UDPPacketLoss float64   `json:"udp_packet_loss_percent,omitempty" csv:"UDP Packet Loss (%)"`
+	NATType       string    `json:"nat_type,omitempty" csv:"NAT Type"`
+	NATMapping    string    `json:"nat_mapping,omitempty" csv:"NAT Mapping"`
+	NATFiltering  string    `json:"nat_filtering,omitempty" csv:"NAT Filtering"`
 	Latency       int64     `json:"latency_ms" csv:"Latency (ms)"`
 	Jitter        int64     `json:"jitter_ms" csv:"Jitter (ms)"`
+	LatencyMin    int64     `json:"latency_min_ms,omitempty" csv:"Latency Min (ms)"`
+	LatencyMedian int64     `json:"latency_median_ms,omitempty" csv:"Latency Median (ms)"`
+	LatencyP90    int64     `json:"latency_p90_ms,omitempty" csv:"Latency P90 (ms)"`
+	LatencyMax    int64     `json:"latency_max_ms,omitempty" csv:"Latency Max (ms)"`
+	ArrivalJitter int64     `json:"arrival_jitter_ms,omitempty" csv:"Arrival Jitter (ms)"`
+	HTTPProbe     int64     `json:"http_probe_ms,omitempty" csv:"HTTP Probe (ms)"`
+	TCPProbe      int64     `json:"tcp_probe_ms,omitempty" csv:"TCP Probe (ms)"`
+	TLSProbe      int64     `json:"tls_probe_ms,omitempty" csv:"TLS Probe (ms)"`
 	PacketLoss    float64   `json:"packet_loss_percent" csv:"Packet Loss (%)"`
-	DownloadSpeed float64   `json:"download_speed_mbps" csv:"Download (Mbps)"`
-	UploadSpeed   float64   `json:"upload_speed_mbps" csv:"Upload (Mbps)"`
+	DialTime      int64     `json:"dial_ms,omitempty" csv:"Dial (ms)"`
+	ConnectTime   int64     `json:"proxy_connect_ms,omitempty" csv:"Proxy Connect (ms)"`
+	HandshakeTime int64     `json:"proxy_handshake_ms,omitempty" csv:"Proxy Handshake (ms)"`
+	TLSTime       int64     `json:"tls_ms,omitempty" csv:"TLS (ms)"`
+	WriteTime     int64     `json:"write_ms,omitempty" csv:"Write (ms)"`
+	TTFB          int64     `json:"ttfb_ms,omitempty" csv:"TTFB (ms)"`
+	SpeedServer   string    `json:"bandwidth_server,omitempty" csv:"Bandwidth Server"`
+	DownloadSpeed float64   `json:"download_speed_mb_s" csv:"Download (MB/s)"`
+	DownloadPeak  float64   `json:"download_peak_mb_s,omitempty" csv:"Download Peak (MB/s)"`
+	UploadSpeed   float64   `json:"upload_speed_mb_s" csv:"Upload (MB/s)"`
+	UploadPeak    float64   `json:"upload_peak_mb_s,omitempty" csv:"Upload Peak (MB/s)"`
+	SingleStream  float64   `json:"single_stream_mb_s,omitempty" csv:"Single Stream (MB/s)"`
+	MultiStream   float64   `json:"multi_stream_mb_s,omitempty" csv:"Multi Stream (MB/s)"`
+	KneeStreams   int       `json:"saturation_knee,omitempty" csv:"Saturation Knee"`
+	StreamLimited bool      `json:"per_stream_limited,omitempty" csv:"Per-Stream Limited"`
+	IdleLatency   int64     `json:"idle_latency_ms,omitempty" csv:"Idle Latency (ms)"`
+	LoadedDown    int64     `json:"loaded_download_latency_ms,omitempty" csv:"Loaded Download Latency (ms)"`
+	LoadedUp      int64     `json:"loaded_upload_latency_ms,omitempty" csv:"Loaded Upload Latency (ms)"`
+	Bufferbloat   string    `json:"bufferbloat_grade,omitempty" csv:"Bufferbloat Grade"`
+	Verified      bool      `json:"payload_verified,omitempty" csv:"Payload Verified"`
+	Integrity     string    `json:"integrity_error,omitempty" csv:"Integrity Error"`
 	TestTime      time.Time `json:"test_time" csv:"Test Time"`
 	Status        string    `json:"status" csv:"Status"`
 	ErrorMessage  string    `json:"error_message,omitempty" csv:"Error Message"`
 
+	// Unlock detection results
+	UnlockedPlatforms []string       `json:"unlocked_platforms,omitempty" csv:"Unlocked Platforms"`
+	UnlockResults     []UnlockResult `json:"unlock_results,omitempty" csv:"-"`
+
+	// Per-interval throughput of timed bandwidth tests in MB/s
+	DownloadSeries []float64 `json:"download_series_mb_s,omitempty" csv:"-"`
+	UploadSeries   []float64 `json:"upload_series_mb_s,omitempty" csv:"-"`
+
+	// Latency to every bandwidth server and throughput of the servers tested
+	ServerResults []ServerResult `json:"server_results,omitempty" csv:"-"`
+
 	// Original proxy configuration for Clash export
 	ProxyConfig map[string]any `json:"proxy_config,omitempty" csv:"-"`
 }
 
+// UnlockResult represents the unlock detection result of a single platform
+type UnlockResult struct {
+	Platform     string `json:"platform" yaml:"platform"`
+	Supported    bool   `json:"supported" yaml:"supported"`
+	Region       string `json:"region,omitempty" yaml:"region,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty" yaml:"error_message,omitempty"`
+}
+
+// ServerResult represents the latency and throughput (MB/s) of a node against one bandwidth server
+type ServerResult struct {
+	Server        string  `json:"server"`
+	Latency       int64   `json:"latency_ms"`
+	PacketLoss    float64 `json:"packet_loss_percent"`
+	DownloadSpeed float64 `json:"download_speed_mb_s,omitempty"`
+	UploadSpeed   float64 `json:"upload_speed_mb_s,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
 // ClashConfig represents a Clash configuration file
 type ClashConfig struct {
 	Port               int              `yaml:"port"`
@@ -95,8 +182,37 @@ func (e *Exporter) SetStatistics(stats *stats.TestStatistics) {
 	e.stats = stats
 }
 
-// Export exports the results in the specified format
+// Export exports the results in the specified format to options.OutputPath
 func (e *Exporter) Export(options ExportOptions) error {
+	file, err := CreateOutputFile(options)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return e.ExportTo(file, options)
+}
+
+// CreateOutputFile creates the file at options.OutputPath, including its directory
+func CreateOutputFile(options ExportOptions) (*os.File, error) {
+	if options.OutputPath == "" {
+		return nil, fmt.Errorf("output path is required")
+	}
+
+	// Create output directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(options.OutputPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	file, err := os.Create(options.OutputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s file: %w", options.Format, err)
+	}
+	return file, nil
+}
+
+// ExportTo writes the results in the specified format to w
+func (e *Exporter) ExportTo(w io.Writer, options ExportOptions) error {
 	// Filter and sort results
 	filteredResults := e.filterResults(options)
 	sortedResults := e.sortResults(filteredResults, options.SortBy)
@@ -106,20 +222,15 @@ func (e *Exporter) Export(options ExportOptions) error {
 		sortedResults = sortedResults[:options.TopN]
 	}
 
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(options.OutputPath), 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
-	}
-
 	switch options.Format {
 	case FormatJSON:
-		return e.exportJSON(sortedResults, options.OutputPath)
+		return e.exportJSON(sortedResults, w)
 	case FormatCSV:
-		return e.exportCSV(sortedResults, options.OutputPath)
+		return e.exportCSV(sortedResults, w)
 	case FormatYAML:
-		return e.exportYAML(sortedResults, options.OutputPath)
+		return e.exportYAML(sortedResults, w)
 	case FormatClash:
-		return e.exportClash(sortedResults, options.OutputPath)
+		return e.exportClash(sortedResults, options, w)
 	default:
 		return fmt.Errorf("unsupported export format: %s", options.Format)
 	}
@@ -148,6 +259,12 @@ func (e *Exporter) filterResults(options ExportOptions) []ExportableResult {
 		if options.MinUpload > 0 && result.UploadSpeed < options.MinUpload {
 			continue
 		}
+		if !unlocksAll(result, options.RequiredPlatforms) {
+			continue
+		}
+		if !containsFold(options.ASNTypes, result.ASNType) {
+			continue
+		}
 
 		filtered = append(filtered, result)
 	}
@@ -157,13 +274,34 @@ func (e *Exporter) filterResults(options ExportOptions) []ExportableResult {
 
 // sortResults sorts results based on the specified field
 func (e *Exporter) sortResults(results []ExportableResult, sortBy string) []ExportableResult {
-	// Implementation would include sorting logic
-	// For now, return as-is
+	var less func(a, b ExportableResult) bool
+	switch sortBy {
+	case "latency":
+		// Results without latency go last
+		less = func(a, b ExportableResult) bool {
+			if (a.Latency == 0) != (b.Latency == 0) {
+				return b.Latency == 0
+			}
+			return a.Latency < b.Latency
+		}
+	case "download":
+		less = func(a, b ExportableResult) bool { return a.DownloadSpeed > b.DownloadSpeed }
+	case "upload":
+		less = func(a, b ExportableResult) bool { return a.UploadSpeed > b.UploadSpeed }
+	case "name":
+		less = func(a, b ExportableResult) bool { return a.ProxyName < b.ProxyName }
+	default:
+		return results
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return less(results[i], results[j])
+	})
 	return results
 }
 
 // exportJSON exports results to JSON format
-func (e *Exporter) exportJSON(results []ExportableResult, outputPath string) error {
+func (e *Exporter) exportJSON(results []ExportableResult, w io.Writer) error {
 	data := struct {
 		Metadata struct {
 			ExportTime   time.Time                `json:"export_time"`
@@ -179,33 +317,30 @@ func (e *Exporter) exportJSON(results []ExportableResult, outputPath string) err
 	data.Metadata.TotalResults = len(results)
 	data.Metadata.Statistics = e.stats
 
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create JSON file: %w", err)
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
+	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
 	return encoder.Encode(data)
 }
 
 // exportCSV exports results to CSV format
-func (e *Exporter) exportCSV(results []ExportableResult, outputPath string) error {
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create CSV file: %w", err)
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+func (e *Exporter) exportCSV(results []ExportableResult, w io.Writer) error {
+	writer := csv.NewWriter(w)
 
 	// Write header
 	header := []string{
 		"Proxy Name", "Proxy Type", "Server", "Port", "Country", "Country Code",
-		"City", "ISP", "Latency (ms)", "Jitter (ms)", "Packet Loss (%)",
-		"Download (Mbps)", "Upload (Mbps)", "Test Time", "Status", "Error Message",
+		"City", "ISP", "Entry IP", "Egress IPv4", "Egress IPv6", "Relayed", "ASN", "AS Org", "ASN Type",
+		"IPv4", "IPv6", "IPv4 Latency (ms)", "IPv6 Latency (ms)",
+		"UDP", "UDP Latency (ms)", "UDP Packet Loss (%)",
+		"NAT Type", "NAT Mapping", "NAT Filtering", "Latency (ms)", "Jitter (ms)", "Packet Loss (%)",
+		"Latency Min (ms)", "Latency Median (ms)", "Latency P90 (ms)", "Latency Max (ms)", "Arrival Jitter (ms)",
+		"HTTP Probe (ms)", "TCP Probe (ms)", "TLS Probe (ms)",
+		"Dial (ms)", "Proxy Connect (ms)", "Proxy Handshake (ms)", "TLS (ms)", "Write (ms)", "TTFB (ms)",
+		"Bandwidth Server", "Download (MB/s)", "Download Peak (MB/s)", "Upload (MB/s)", "Upload Peak (MB/s)",
+		"Single Stream (MB/s)", "Multi Stream (MB/s)", "Saturation Knee", "Per-Stream Limited",
+		"Idle Latency (ms)", "Loaded Download Latency (ms)", "Loaded Upload Latency (ms)", "Bufferbloat Grade",
+		"Payload Verified", "Integrity Error", "Test Time", "Status", "Error Message",
+		"Unlocked Platforms",
 	}
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
@@ -222,25 +357,71 @@ func (e *Exporter) exportCSV(results []ExportableResult, outputPath string) erro
 			result.CountryCode,
 			result.City,
 			result.ISP,
+			result.EntryIP,
+			result.EgressIPv4,
+			result.EgressIPv6,
+			strconv.FormatBool(result.Relayed),
+			formatASN(result.ASN),
+			result.ASOrg,
+			result.ASNType,
+			strconv.FormatBool(result.IPv4OK),
+			strconv.FormatBool(result.IPv6OK),
+			fmt.Sprintf("%d", result.IPv4Latency),
+			fmt.Sprintf("%d", result.IPv6Latency),
+			strconv.FormatBool(result.UDPSupported),
+			fmt.Sprintf("%d", result.UDPLatency),
+			fmt.Sprintf("%.2f", result.UDPPacketLoss),
+			result.NATType,
+			result.NATMapping,
+			result.NATFiltering,
 			fmt.Sprintf("%d", result.Latency),
 			fmt.Sprintf("%d", result.Jitter),
 			fmt.Sprintf("%.2f", result.PacketLoss),
+			fmt.Sprintf("%d", result.LatencyMin),
+			fmt.Sprintf("%d", result.LatencyMedian),
+			fmt.Sprintf("%d", result.LatencyP90),
+			fmt.Sprintf("%d", result.LatencyMax),
+			fmt.Sprintf("%d", result.ArrivalJitter),
+			fmt.Sprintf("%d", result.HTTPProbe),
+			fmt.Sprintf("%d", result.TCPProbe),
+			fmt.Sprintf("%d", result.TLSProbe),
+			fmt.Sprintf("%d", result.DialTime),
+			fmt.Sprintf("%d", result.ConnectTime),
+			fmt.Sprintf("%d", result.HandshakeTime),
+			fmt.Sprintf("%d", result.TLSTime),
+			fmt.Sprintf("%d", result.WriteTime),
+			fmt.Sprintf("%d", result.TTFB),
+			result.SpeedServer,
 			fmt.Sprintf("%.2f", result.DownloadSpeed),
+			fmt.Sprintf("%.2f", result.DownloadPeak),
 			fmt.Sprintf("%.2f", result.UploadSpeed),
+			fmt.Sprintf("%.2f", result.UploadPeak),
+			fmt.Sprintf("%.2f", result.SingleStream),
+			fmt.Sprintf("%.2f", result.MultiStream),
+			fmt.Sprintf("%d", result.KneeStreams),
+			strconv.FormatBool(result.StreamLimited),
+			fmt.Sprintf("%d", result.IdleLatency),
+			fmt.Sprintf("%d", result.LoadedDown),
+			fmt.Sprintf("%d", result.LoadedUp),
+			result.Bufferbloat,
+			strconv.FormatBool(result.Verified),
+			result.Integrity,
 			result.TestTime.Format("2006-01-02 15:04:05"),
 			result.Status,
 			result.ErrorMessage,
+			strings.Join(result.UnlockedPlatforms, ";"),
 		}
 		if err := writer.Write(row); err != nil {
 			return fmt.Errorf("failed to write CSV row: %w", err)
 		}
 	}
 
-	return nil
+	writer.Flush()
+	return writer.Error()
 }
 
 // exportYAML exports results to YAML format
-func (e *Exporter) exportYAML(results []ExportableResult, outputPath string) error {
+func (e *Exporter) exportYAML(results []ExportableResult, w io.Writer) error {
 	data := struct {
 		Metadata struct {
 			ExportTime   time.Time                `yaml:"export_time"`
@@ -256,19 +437,13 @@ func (e *Exporter) exportYAML(results []ExportableResult, outputPath string) err
 	data.Metadata.TotalResults = len(results)
 	data.Metadata.Statistics = e.stats
 
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create YAML file: %w", err)
-	}
-	defer file.Close()
-
-	encoder := yaml.NewEncoder(file)
+	encoder := yaml.NewEncoder(w)
 	defer encoder.Close()
 	return encoder.Encode(data)
 }
 
 // exportClash exports results to Clash configuration format
-func (e *Exporter) exportClash(results []ExportableResult, outputPath string) error {
+func (e *Exporter) exportClash(results []ExportableResult, options ExportOptions, w io.Writer) error {
 	config := ClashConfig{
 		Port:               7890,
 		SocksPort:          7891,
@@ -277,82 +452,111 @@ func (e *Exporter) exportClash(results []ExportableResult, outputPath string) er
 		LogLevel:           "info",
 		ExternalController: "127.0.0.1:9090",
 		Proxies:            make([]map[string]any, 0),
-		ProxyGroups: []map[string]any{
-			{
-				"name":    "🚀 节点选择",
-				"type":    "select",
-				"proxies": []string{"♻️ 自动选择", "🎯 全球直连"},
-			},
-			{
-				"name":     "♻️ 自动选择",
-				"type":     "url-test",
-				"proxies":  []string{},
-				"url":      "http://www.gstatic.com/generate_204",
-				"interval": 300,
-			},
-			{
-				"name":    "🎯 全球直连",
-				"type":    "select",
-				"proxies": []string{"DIRECT"},
-			},
-		},
-		Rules: []string{
-			"DOMAIN-SUFFIX,local,DIRECT",
-			"IP-CIDR,127.0.0.0/8,DIRECT",
-			"IP-CIDR,172.16.0.0/12,DIRECT",
-			"IP-CIDR,192.168.0.0/16,DIRECT",
-			"IP-CIDR,10.0.0.0/8,DIRECT",
-			"IP-CIDR,17.0.0.0/8,DIRECT",
-			"IP-CIDR,100.64.0.0/10,DIRECT",
-			"GEOIP,CN,DIRECT",
-			"MATCH,🚀 节点选择",
-		},
 	}
 
 	// Add successful proxies to config
-	var proxyNames []string
+	var nodes []clashNode
 	for _, result := range results {
 		if result.Status == "success" && result.ProxyConfig != nil {
-			// Add speed information to proxy name
-			enhancedName := fmt.Sprintf("%s | ⬇️%.1fM ⬆️%.1fM ⏱️%dms",
-				result.ProxyName,
-				result.DownloadSpeed,
-				result.UploadSpeed,
-				result.Latency,
-			)
+			name := result.ProxyName
+			if !options.KeepProxyNames {
+				// Add speed information to proxy name
+				name = fmt.Sprintf("%s | ⬇️%.1fM ⬆️%.1fM ⏱️%dms",
+					result.ProxyName,
+					result.DownloadSpeed,
+					result.UploadSpeed,
+					result.Latency,
+				)
+			}
 
 			// Create a copy of the proxy config and update the name
 			proxyConfig := make(map[string]any)
 			for k, v := range result.ProxyConfig {
 				proxyConfig[k] = v
 			}
-			proxyConfig["name"] = enhancedName
+			proxyConfig["name"] = name
 
 			config.Proxies = append(config.Proxies, proxyConfig)
-			proxyNames = append(proxyNames, enhancedName)
+			nodes = append(nodes, clashNode{name: name, result: result})
 		}
 	}
 
-	// Update auto-select group with proxy names
-	if len(proxyNames) > 0 {
-		config.ProxyGroups[1]["proxies"] = proxyNames
+	config.ProxyGroups = buildProxyGroups(options.ProxyGroups, nodes)
+
+	// Unmatched traffic goes to the first group
+	matchTarget := "DIRECT"
+	if len(config.ProxyGroups) > 0 {
+		matchTarget = config.ProxyGroups[0]["name"].(string)
 	}
 
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create Clash config file: %w", err)
+	config.Rules = []string{
+		"DOMAIN-SUFFIX,local,DIRECT",
+		"IP-CIDR,127.0.0.0/8,DIRECT",
+		"IP-CIDR,172.16.0.0/12,DIRECT",
+		"IP-CIDR,192.168.0.0/16,DIRECT",
+		"IP-CIDR,10.0.0.0/8,DIRECT",
+		"IP-CIDR,17.0.0.0/8,DIRECT",
+		"IP-CIDR,100.64.0.0/10,DIRECT",
+		"GEOIP,CN,DIRECT",
+		"MATCH," + matchTarget,
 	}
-	defer file.Close()
 
-	encoder := yaml.NewEncoder(file)
+	encoder := yaml.NewEncoder(w)
 	defer encoder.Close()
 	return encoder.Encode(config)
 }
 
+// formatASN formats an ASN for CSV output, empty when unknown
+func formatASN(asn uint) string {
+	if asn == 0 {
+		return ""
+	}
+	return fmt.Sprintf("AS%d", asn)
+}
+
+// unlocksAll reports whether the result unlocks every required platform
+func unlocksAll(result ExportableResult, platforms []string) bool {
+	for _, platform := range platforms {
+		found := false
+		for _, unlocked := range result.UnlockedPlatforms {
+			if strings.EqualFold(unlocked, platform) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 // GenerateFilename generates a filename with timestamp
 func GenerateFilename(prefix string, format ExportFormat) string {
 	timestamp := time.Now().Format("20060102_150405")
-	return fmt.Sprintf("%s_%s.%s", prefix, timestamp, string(format))
+	return fmt.Sprintf("%s_%s.%s", prefix, timestamp, FileExtension(format))
+}
+
+// FileExtension returns the file extension for an export format
+func FileExtension(format ExportFormat) string {
+	if format == FormatClash {
+		return "yaml"
+	}
+	return string(format)
+}
+
+// ContentType returns the MIME type for an export format
+func ContentType(format ExportFormat) string {
+	switch format {
+	case FormatJSON:
+		return "application/json"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatYAML, FormatClash:
+		return "application/yaml"
+	default:
+		return "application/octet-stream"
+	}
 }
 
 // GetSupportedFormats returns all supported export formats
@@ -362,10 +566,6 @@ func GetSupportedFormats() []ExportFormat {
 
 // ValidateExportOptions validates export options
 func ValidateExportOptions(options ExportOptions) error {
-	if options.OutputPath == "" {
-		return fmt.Errorf("output path is required")
-	}
-
 	supportedFormats := GetSupportedFormats()
 	formatSupported := false
 	for _, format := range supportedFormats {
@@ -383,6 +583,17 @@ func ValidateExportOptions(options ExportOptions) error {
 		return fmt.Errorf("top_n must be non-negative")
 	}
 
+	if err := ValidateProxyGroups(options.ProxyGroups); err != nil {
+		return err
+	}
+
+	for _, asnType := range options.ASNTypes {
+		if !geo.IsASNCategory(strings.ToLower(asnType)) {
+			return fmt.Errorf("unsupported asn type: %s, supported types: %s",
+				asnType, strings.Join(geo.ASNCategories, ", "))
+		}
+	}
+
 	return nil
 }
 
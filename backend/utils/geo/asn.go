@@ -0,0 +1,171 @@
+package geo
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ASN categories
+const (
+	ASNResidential = "residential"
+	ASNDatacenter  = "datacenter"
+	ASNMobile      = "mobile"
+	ASNEducation   = "education"
+)
+
+// ASNCategories lists the supported ASN categories
+var ASNCategories = []string{ASNResidential, ASNDatacenter, ASNMobile, ASNEducation}
+
+//go:embed asn_categories.txt
+var bundledASNTable string
+
+// orgKeywords classifies ASNs missing from the table by their organization
+// name, checked in order
+var orgKeywords = []struct {
+	category string
+	keywords []string
+}{
+	{ASNEducation, []string{"university", "college", "academ", "education", "school", "research network"}},
+	{ASNMobile, []string{"mobile", "wireless", "cellular"}},
+	{ASNDatacenter, []string{"hosting", "host", "cloud", "data center", "datacenter", "server", "vps", "colocation", "dedicated"}},
+}
+
+var (
+	asnTable      map[uint]string
+	asnTableMutex sync.RWMutex
+)
+
+// ParseASNTable reads an ASN category table with one "<asn> <category>" entry
+// per line; the ASN may carry an "AS" prefix and "#" starts a comment
+func ParseASNTable(r io.Reader) (map[uint]string, error) {
+	table := make(map[uint]string)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<asn> <category>\"", line)
+		}
+
+		asn, err := ParseASN(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		category := strings.ToLower(fields[1])
+		if !IsASNCategory(category) {
+			return nil, fmt.Errorf("line %d: unknown category %q, supported: %s", line, fields[1], strings.Join(ASNCategories, ", "))
+		}
+		table[asn] = category
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// LoadASNTable merges the table at path over the bundled table and makes it
+// the table used by ClassifyASN
+func LoadASNTable(path string) error {
+	table, err := ParseASNTable(strings.NewReader(bundledASNTable))
+	if err != nil {
+		return fmt.Errorf("invalid bundled ASN table: %w", err)
+	}
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open ASN table: %w", err)
+		}
+		defer file.Close()
+
+		overrides, err := ParseASNTable(file)
+		if err != nil {
+			return fmt.Errorf("invalid ASN table %s: %w", path, err)
+		}
+		for asn, category := range overrides {
+			table[asn] = category
+		}
+	}
+
+	asnTableMutex.Lock()
+	asnTable = table
+	asnTableMutex.Unlock()
+	return nil
+}
+
+// ClassifyASN returns the category of an ASN from the table, falling back to
+// keywords in the organization name; it returns "" when unknown
+func ClassifyASN(asn uint, org string) string {
+	asnTableMutex.RLock()
+	if asnTable == nil {
+		asnTableMutex.RUnlock()
+		if err := LoadASNTable(""); err != nil {
+			return ""
+		}
+		asnTableMutex.RLock()
+	}
+	category, ok := asnTable[asn]
+	asnTableMutex.RUnlock()
+	if ok {
+		return category
+	}
+
+	org = strings.ToLower(org)
+	if org == "" {
+		return ""
+	}
+	for _, rule := range orgKeywords {
+		for _, keyword := range rule.keywords {
+			if strings.Contains(org, keyword) {
+				return rule.category
+			}
+		}
+	}
+	return ""
+}
+
+// ParseASN parses an ASN such as "AS16509" or "16509"
+func ParseASN(s string) (uint, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 2 && strings.EqualFold(s[:2], "AS") {
+		s = s[2:]
+	}
+	asn, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || asn == 0 {
+		return 0, fmt.Errorf("invalid ASN %q", s)
+	}
+	return uint(asn), nil
+}
+
+// IsASNCategory reports whether category is a supported ASN category
+func IsASNCategory(category string) bool {
+	for _, supported := range ASNCategories {
+		if category == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// ASInfo returns the ASN and organization of a location, parsed from its
+// "AS16509 Amazon.com, Inc." style AS field
+func (g *GeoLocation) ASInfo() (uint, string) {
+	number, org, _ := strings.Cut(strings.TrimSpace(g.AS), " ")
+	asn, err := ParseASN(number)
+	if err != nil {
+		return 0, ""
+	}
+	if org == "" {
+		org = g.Org
+	}
+	return asn, strings.TrimSpace(org)
+}
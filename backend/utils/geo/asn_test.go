@@ -0,0 +1,90 @@
+package geo
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParseASNTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[uint]string
+		wantErr string
+	}{
+		{
+			name:  "empty",
+			input: "",
+			want:  map[uint]string{},
+		},
+		{
+			name:  "comments and blank lines",
+			input: "# header\n\n   \n13335 datacenter # Cloudflare\n",
+			want:  map[uint]string{13335: ASNDatacenter},
+		},
+		{
+			name:  "AS prefix and case",
+			input: "AS4134 Residential\nas9808 MOBILE\n",
+			want:  map[uint]string{4134: ASNResidential, 9808: ASNMobile},
+		},
+		{
+			name:  "later entry wins",
+			input: "16509 datacenter\n16509 residential\n",
+			want:  map[uint]string{16509: ASNResidential},
+		},
+		{
+			name:    "missing category",
+			input:   "13335 datacenter\n15169\n",
+			wantErr: "line 2",
+		},
+		{
+			name:    "extra field",
+			input:   "13335 datacenter cloudflare\n",
+			wantErr: "line 1",
+		},
+		{
+			name:    "invalid ASN",
+			input:   "ASX datacenter\n",
+			wantErr: "invalid ASN",
+		},
+		{
+			name:    "zero ASN",
+			input:   "0 datacenter\n",
+			wantErr: "invalid ASN",
+		},
+		{
+			name:    "unknown category",
+			input:   "13335 satellite\n",
+			wantErr: "unknown category",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseASNTable(strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseASNTable() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseASNTable() error: %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("ParseASNTable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBundledASNTable(t *testing.T) {
+	table, err := ParseASNTable(strings.NewReader(bundledASNTable))
+	if err != nil {
+		t.Fatalf("bundled ASN table is invalid: %v", err)
+	}
+	if len(table) == 0 {
+		t.Fatal("bundled ASN table is empty")
+	}
+}
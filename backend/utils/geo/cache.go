@@ -0,0 +1,101 @@
+package geo
+
+import (
+	"container/list"
+	"io"
+	"sync"
+)
+
+// LocationCache is an LRU cache of locations by IP in front of a GeoProvider
+type LocationCache struct {
+	provider GeoProvider
+	size     int
+	entries  map[string]*list.Element
+	order    *list.List // front is most recently used
+	mutex    sync.Mutex
+}
+
+// cacheEntry is a cached location
+type cacheEntry struct {
+	ip       string
+	location *GeoLocation
+}
+
+// NewLocationCache creates a location cache holding up to size entries
+func NewLocationCache(provider GeoProvider, size int) *LocationCache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &LocationCache{
+		provider: provider,
+		size:     size,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Lookup returns the location of a server address (domain or IP).
+// Failed lookups return nil and are not cached.
+func (c *LocationCache) Lookup(server string) *GeoLocation {
+	location, err := c.GetLocationByIP(ExtractIPFromServer(server))
+	if err != nil {
+		return nil
+	}
+	return location
+}
+
+// GetLocationByIP implements GeoProvider
+func (c *LocationCache) GetLocationByIP(ip string) (*GeoLocation, error) {
+	if location, ok := c.get(ip); ok {
+		return location, nil
+	}
+
+	// The lock is not held during the lookup: concurrent misses for the same IP
+	// may query twice, but slow lookups never block cache hits
+	location, err := c.provider.GetLocationByIP(ip)
+	if err != nil {
+		return nil, err
+	}
+	c.put(ip, location)
+	return location, nil
+}
+
+// get returns a cached location and marks it as recently used
+func (c *LocationCache) get(ip string) (*GeoLocation, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[ip]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).location, true
+}
+
+// put stores a location, evicting the least recently used entries beyond size
+func (c *LocationCache) put(ip string, location *GeoLocation) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[ip]; ok {
+		element.Value.(*cacheEntry).location = location
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[ip] = c.order.PushFront(&cacheEntry{ip: ip, location: location})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).ip)
+	}
+}
+
+// Close closes the underlying provider when it holds resources
+func (c *LocationCache) Close() error {
+	if closer, ok := c.provider.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -2,11 +2,14 @@ package geo
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/zhsama/clash-speedtest/logger"
@@ -71,10 +74,18 @@ var CountryFlags = map[string]string{
 	"YT": "🇾🇹", "ZA": "🇿🇦", "ZM": "🇿🇲", "ZW": "🇿🇼",
 }
 
+// ErrRateLimited is returned while the geo API rate limit window is exhausted
+var ErrRateLimited = errors.New("geo API rate limited")
+
+// defaultRateLimitBackoff is used when a 429 response carries no X-Ttl header
+const defaultRateLimitBackoff = time.Minute
+
 // GeoService provides geographical location services
 type GeoService struct {
-	client  *http.Client
-	baseURL string
+	client       *http.Client
+	baseURL      string
+	blockedUntil time.Time // Requests fail fast until the rate limit window resets
+	mutex        sync.Mutex
 }
 
 // NewGeoService creates a new geographical location service
@@ -94,6 +105,10 @@ func (g *GeoService) GetLocationByIP(ip string) (*GeoLocation, error) {
 		return nil, fmt.Errorf("invalid IP address: %s", ip)
 	}
 
+	if until := g.rateLimitedUntil(); !until.IsZero() {
+		return nil, fmt.Errorf("%w until %s", ErrRateLimited, until.Format(time.TimeOnly))
+	}
+
 	url := fmt.Sprintf("%s/%s?fields=status,message,country,countryCode,region,regionName,city,zip,lat,lon,timezone,isp,org,as,query", g.baseURL, ip)
 
 	logger.Logger.Debug("Fetching geo location",
@@ -108,6 +123,11 @@ func (g *GeoService) GetLocationByIP(ip string) (*GeoLocation, error) {
 	}
 	defer resp.Body.Close()
 
+	g.updateRateLimit(resp)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w for IP %s", ErrRateLimited, ip)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("geo API returned status %d for IP %s", resp.StatusCode, ip)
 	}
@@ -132,6 +152,40 @@ func (g *GeoService) GetLocationByIP(ip string) (*GeoLocation, error) {
 	return &location, nil
 }
 
+// rateLimitedUntil returns the end of the current rate limit block, or zero if not blocked
+func (g *GeoService) rateLimitedUntil() time.Time {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if time.Now().Before(g.blockedUntil) {
+		return g.blockedUntil
+	}
+	return time.Time{}
+}
+
+// updateRateLimit reads ip-api.com's X-Rl (requests left) and X-Ttl (seconds
+// until reset) headers and blocks further requests once the quota is used up
+func (g *GeoService) updateRateLimit(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-Rl"))
+	exhausted := err == nil && remaining <= 0
+	if !exhausted && resp.StatusCode != http.StatusTooManyRequests {
+		return
+	}
+
+	backoff := defaultRateLimitBackoff
+	if ttl, err := strconv.Atoi(resp.Header.Get("X-Ttl")); err == nil && ttl >= 0 {
+		backoff = time.Duration(ttl+1) * time.Second
+	}
+
+	g.mutex.Lock()
+	g.blockedUntil = time.Now().Add(backoff)
+	g.mutex.Unlock()
+
+	logger.Logger.Warn("Geo API rate limit reached, pausing lookups",
+		slog.Duration("backoff", backoff),
+	)
+}
+
 // GetFlagEmoji returns the flag emoji for a country code
 func GetFlagEmoji(countryCode string) string {
 	if flag, ok := CountryFlags[strings.ToUpper(countryCode)]; ok {
@@ -0,0 +1,141 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// mmdbLocationRecord holds the fields read from GeoLite2-City/Country databases
+type mmdbLocationRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Postal struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"postal"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+		TimeZone  string  `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+}
+
+// mmdbASNRecord holds the fields read from GeoLite2-ASN databases
+type mmdbASNRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// MMDBProvider looks up locations in local MaxMind-format databases
+type MMDBProvider struct {
+	location *maxminddb.Reader
+	asn      *maxminddb.Reader
+	mutex    sync.RWMutex // Close waits for in-flight lookups
+}
+
+// NewMMDBProvider opens a city or country database and an ASN database; either path may be empty
+func NewMMDBProvider(locationPath, asnPath string) (*MMDBProvider, error) {
+	if locationPath == "" && asnPath == "" {
+		return nil, errors.New("no MMDB database configured")
+	}
+
+	provider := &MMDBProvider{}
+	if locationPath != "" {
+		reader, err := maxminddb.Open(locationPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open location database %s: %w", locationPath, err)
+		}
+		provider.location = reader
+	}
+	if asnPath != "" {
+		reader, err := maxminddb.Open(asnPath)
+		if err != nil {
+			provider.Close()
+			return nil, fmt.Errorf("failed to open ASN database %s: %w", asnPath, err)
+		}
+		provider.asn = reader
+	}
+	return provider, nil
+}
+
+// GetLocationByIP implements GeoProvider
+func (p *MMDBProvider) GetLocationByIP(ip string) (*GeoLocation, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	location := &GeoLocation{Query: ip, Status: "success"}
+	found := false
+
+	if p.location != nil {
+		var record mmdbLocationRecord
+		if err := p.location.Lookup(parsed, &record); err != nil {
+			return nil, fmt.Errorf("failed to read location database for %s: %w", ip, err)
+		}
+		if record.Country.ISOCode != "" {
+			found = true
+			location.Country = record.Country.Names["en"]
+			location.CountryCode = record.Country.ISOCode
+			location.City = record.City.Names["en"]
+			location.Zip = record.Postal.Code
+			location.Lat = record.Location.Latitude
+			location.Lon = record.Location.Longitude
+			location.Timezone = record.Location.TimeZone
+			if len(record.Subdivisions) > 0 {
+				location.Region = record.Subdivisions[0].ISOCode
+				location.RegionName = record.Subdivisions[0].Names["en"]
+			}
+		}
+	}
+
+	if p.asn != nil {
+		var record mmdbASNRecord
+		if err := p.asn.Lookup(parsed, &record); err != nil {
+			return nil, fmt.Errorf("failed to read ASN database for %s: %w", ip, err)
+		}
+		if record.Number != 0 {
+			found = true
+			location.AS = fmt.Sprintf("AS%d %s", record.Number, record.Organization)
+			location.Org = record.Organization
+			location.ISP = record.Organization
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%s: %w", ip, ErrNotFound)
+	}
+	return location, nil
+}
+
+// Close closes the underlying databases; later lookups return ErrNotFound
+func (p *MMDBProvider) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var errs []error
+	if p.location != nil {
+		errs = append(errs, p.location.Close())
+		p.location = nil
+	}
+	if p.asn != nil {
+		errs = append(errs, p.asn.Close())
+		p.asn = nil
+	}
+	return errors.Join(errs...)
+}
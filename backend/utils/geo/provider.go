@@ -0,0 +1,135 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/zhsama/clash-speedtest/logger"
+)
+
+// DefaultCacheSize is the number of locations kept by the default cache
+const DefaultCacheSize = 4096
+
+// ErrNotFound is returned when a provider has no data for an IP
+var ErrNotFound = errors.New("location not found")
+
+// GeoProvider looks up the location of an IP address
+type GeoProvider interface {
+	GetLocationByIP(ip string) (*GeoLocation, error)
+}
+
+// Options configures the default provider
+type Options struct {
+	CityDB       string // GeoLite2-City compatible database, preferred over CountryDB
+	CountryDB    string // GeoLite2-Country compatible database
+	ASNDB        string // GeoLite2-ASN compatible database
+	ASNTable     string // ASN category table merged over the bundled one
+	HTTPFallback bool   // Fall back to ip-api.com when the databases have no answer
+	CacheSize    int    // LRU cache size, DefaultCacheSize when <= 0
+}
+
+// ChainProvider tries providers in order and returns the first answer
+type ChainProvider struct {
+	providers []GeoProvider
+}
+
+// NewChainProvider creates a provider chain
+func NewChainProvider(providers ...GeoProvider) *ChainProvider {
+	return &ChainProvider{providers: providers}
+}
+
+// GetLocationByIP implements GeoProvider
+func (c *ChainProvider) GetLocationByIP(ip string) (*GeoLocation, error) {
+	errs := make([]error, 0, len(c.providers))
+	for _, provider := range c.providers {
+		location, err := provider.GetLocationByIP(ip)
+		if err == nil {
+			return location, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no geo provider configured: %w", ErrNotFound)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Close closes the providers that hold resources, such as MMDB databases
+func (c *ChainProvider) Close() error {
+	var errs []error
+	for _, provider := range c.providers {
+		if closer, ok := provider.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var (
+	defaultCache *LocationCache
+	defaultMutex sync.Mutex
+)
+
+// Configure builds the default provider from options: the MMDB databases
+// (when given) chained with the HTTP service, behind an LRU cache.
+// On error the current default provider is kept.
+func Configure(options Options) error {
+	if err := LoadASNTable(options.ASNTable); err != nil {
+		return err
+	}
+
+	var providers []GeoProvider
+
+	if options.CityDB != "" || options.CountryDB != "" || options.ASNDB != "" {
+		locationDB := options.CityDB
+		if locationDB == "" {
+			locationDB = options.CountryDB
+		}
+		mmdb, err := NewMMDBProvider(locationDB, options.ASNDB)
+		if err != nil {
+			return err
+		}
+		providers = append(providers, mmdb)
+	}
+	if options.HTTPFallback {
+		providers = append(providers, NewGeoService())
+	}
+
+	cache := NewLocationCache(NewChainProvider(providers...), options.CacheSize)
+
+	defaultMutex.Lock()
+	previous := defaultCache
+	defaultCache = cache
+	defaultMutex.Unlock()
+
+	// Release the databases of the replaced provider
+	if previous != nil {
+		if err := previous.Close(); err != nil {
+			logger.LogError("Failed to close previous geo provider", err)
+		}
+	}
+
+	logger.Logger.Info("Geo provider configured",
+		slog.String("city_db", options.CityDB),
+		slog.String("country_db", options.CountryDB),
+		slog.String("asn_db", options.ASNDB),
+		slog.String("asn_table", options.ASNTable),
+		slog.Bool("http_fallback", options.HTTPFallback),
+	)
+	return nil
+}
+
+// Default returns the process-wide cached provider, falling back to the
+// HTTP service when Configure has not been called
+func Default() *LocationCache {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+
+	if defaultCache == nil {
+		defaultCache = NewLocationCache(NewGeoService(), DefaultCacheSize)
+	}
+	return defaultCache
+}
@@ -0,0 +1,201 @@
+package stun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"time"
+)
+
+// NAT mapping and filtering behaviors (RFC 5780 section 4)
+const (
+	EndpointIndependent  = "endpoint-independent"
+	AddressDependent     = "address-dependent"
+	AddressPortDependent = "address-port-dependent"
+	BehaviorUnknown      = "unknown"
+)
+
+// NAT types in classic (RFC 3489) terms
+const (
+	NATFullCone           = "full-cone"
+	NATRestrictedCone     = "restricted-cone"
+	NATPortRestrictedCone = "port-restricted-cone"
+	NATSymmetric          = "symmetric"
+	NATUnknown            = "unknown"
+)
+
+// DefaultRequestTimeout is how long to wait for each response before giving up
+const DefaultRequestTimeout = 2 * time.Second
+
+// requestAttempts is the number of transmissions per request
+const requestAttempts = 2
+
+// ErrNoResponse is returned when the server never answers the first binding request
+var ErrNoResponse = errors.New("no response from STUN server")
+
+// Result is the outcome of NAT behavior discovery
+type Result struct {
+	MappedAddress netip.AddrPort // Public address of the first binding
+	Mapping       string         // EndpointIndependent, AddressDependent, AddressPortDependent or BehaviorUnknown
+	Filtering     string         // Same vocabulary as Mapping
+	NATType       string         // Classic NAT type derived from mapping and filtering
+}
+
+// Discover runs RFC 5780 mapping and filtering tests against server over
+// conn. The server must report OTHER-ADDRESS and honor CHANGE-REQUEST for a
+// full classification; otherwise only the mapped address is known.
+//
+// Filtering tests run before mapping tests, since sending to the alternate
+// address would open the NAT's filter for it.
+//
+// timeout bounds the wait for each response and ctx bounds the whole run; a
+// request cut short by ctx returns its error rather than counting as
+// unanswered, so an expired deadline never skews the classification.
+func Discover(ctx context.Context, conn net.PacketConn, server netip.AddrPort, timeout time.Duration) (*Result, error) {
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	c := &client{ctx: ctx, conn: conn, timeout: timeout}
+
+	result := &Result{Mapping: BehaviorUnknown, Filtering: BehaviorUnknown, NATType: NATUnknown}
+
+	first, err := c.bind(server, 0)
+	if err != nil {
+		return nil, err
+	}
+	if first == nil {
+		return nil, ErrNoResponse
+	}
+	result.MappedAddress = first.mapped
+
+	other := first.other
+	if !other.IsValid() || other.Addr() == server.Addr() || other.Port() == server.Port() {
+		return result, nil
+	}
+
+	// Filtering: a response from the alternate IP and port, then from the alternate port only
+	if resp, err := c.bind(server, changeIP|changePort); err != nil {
+		return nil, err
+	} else if resp != nil {
+		result.Filtering = EndpointIndependent
+	} else if resp, err := c.bind(server, changePort); err != nil {
+		return nil, err
+	} else if resp != nil {
+		result.Filtering = AddressDependent
+	} else {
+		result.Filtering = AddressPortDependent
+	}
+
+	// Mapping: compare the mapped address seen by the alternate IP, then by the alternate IP and port
+	second, err := c.bind(netip.AddrPortFrom(other.Addr(), server.Port()), 0)
+	if err != nil {
+		return nil, err
+	}
+	if second != nil {
+		if second.mapped == first.mapped {
+			result.Mapping = EndpointIndependent
+		} else if third, err := c.bind(other, 0); err != nil {
+			return nil, err
+		} else if third != nil {
+			if third.mapped == second.mapped {
+				result.Mapping = AddressDependent
+			} else {
+				result.Mapping = AddressPortDependent
+			}
+		}
+	}
+
+	result.NATType = classify(result.Mapping, result.Filtering)
+	return result, nil
+}
+
+// classify maps RFC 5780 behaviors to the classic NAT types
+func classify(mapping, filtering string) string {
+	switch mapping {
+	case AddressDependent, AddressPortDependent:
+		return NATSymmetric
+	case EndpointIndependent:
+		switch filtering {
+		case EndpointIndependent:
+			return NATFullCone
+		case AddressDependent:
+			return NATRestrictedCone
+		case AddressPortDependent:
+			return NATPortRestrictedCone
+		}
+	}
+	return NATUnknown
+}
+
+// client sends binding requests over a packet connection
+type client struct {
+	ctx     context.Context
+	conn    net.PacketConn
+	timeout time.Duration
+	buf     [1500]byte
+}
+
+// bind sends a binding request and waits for its response. A nil message
+// with a nil error means the request timed out.
+func (c *client) bind(server netip.AddrPort, change uint32) (*message, error) {
+	request := &message{
+		typ:           typeBindingRequest,
+		transactionID: newTransactionID(),
+		changeRequest: change,
+	}
+	packet := request.encode()
+	addr := net.UDPAddrFromAddrPort(server)
+
+	for range requestAttempts {
+		if err := c.ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, err := c.conn.WriteTo(packet, addr); err != nil {
+			return nil, fmt.Errorf("failed to send STUN request: %w", err)
+		}
+
+		deadline, capped := time.Now().Add(c.timeout), false
+		if ctxDeadline, ok := c.ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline, capped = ctxDeadline, true
+		}
+		resp, err := c.await(request.transactionID, deadline)
+		if err != nil || resp != nil {
+			return resp, err
+		}
+		if capped {
+			return nil, context.DeadlineExceeded
+		}
+	}
+	return nil, nil
+}
+
+// await reads until the response to transactionID arrives or the deadline passes
+func (c *client) await(transactionID [12]byte, deadline time.Time) (*message, error) {
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	for {
+		n, _, err := c.conn.ReadFrom(c.buf[:])
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil, nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		resp, err := decodeMessage(c.buf[:n])
+		if err != nil || resp.typ != typeBindingResponse || resp.transactionID != transactionID {
+			continue // Stale responses and unrelated datagrams
+		}
+		return resp, nil
+	}
+}
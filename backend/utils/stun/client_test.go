@@ -0,0 +1,191 @@
+package stun
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		mapping   string
+		filtering string
+		want      string
+	}{
+		{EndpointIndependent, EndpointIndependent, NATFullCone},
+		{EndpointIndependent, AddressDependent, NATRestrictedCone},
+		{EndpointIndependent, AddressPortDependent, NATPortRestrictedCone},
+		{EndpointIndependent, BehaviorUnknown, NATUnknown},
+		{AddressDependent, EndpointIndependent, NATSymmetric},
+		{AddressDependent, AddressPortDependent, NATSymmetric},
+		{AddressPortDependent, AddressPortDependent, NATSymmetric},
+		{AddressPortDependent, BehaviorUnknown, NATSymmetric},
+		{BehaviorUnknown, EndpointIndependent, NATUnknown},
+		{BehaviorUnknown, BehaviorUnknown, NATUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mapping+"/"+tt.filtering, func(t *testing.T) {
+			if got := classify(tt.mapping, tt.filtering); got != tt.want {
+				t.Errorf("classify(%q, %q) = %q, want %q", tt.mapping, tt.filtering, got, tt.want)
+			}
+		})
+	}
+}
+
+// startServer runs a STUN responder on 127.0.0.1 and 127.0.0.2, retrying
+// random port pairs until both are free
+func startServer(t *testing.T) *Server {
+	t.Helper()
+
+	var lastErr error
+	for range 10 {
+		port := uint16(20000 + rand.IntN(20000))
+		server, err := NewServer(netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("127.0.0.2"), port, port+1)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		go server.Serve()
+		t.Cleanup(func() { server.Close() })
+		return server
+	}
+	t.Skipf("cannot start STUN server on loopback: %v", lastErr)
+	return nil
+}
+
+// filteringConn simulates NAT filtering by dropping datagrams from addresses
+// the client has not sent to
+type filteringConn struct {
+	net.PacketConn
+	filtering string
+
+	mutex sync.Mutex
+	sent  map[netip.AddrPort]bool
+}
+
+func (c *filteringConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.mutex.Lock()
+	c.sent[addr.(*net.UDPAddr).AddrPort()] = true
+	c.mutex.Unlock()
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+func (c *filteringConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(p)
+		if err != nil || c.allowed(addr.(*net.UDPAddr).AddrPort()) {
+			return n, addr, err
+		}
+	}
+}
+
+func (c *filteringConn) allowed(from netip.AddrPort) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch c.filtering {
+	case AddressDependent:
+		for to := range c.sent {
+			if to.Addr() == from.Addr() {
+				return true
+			}
+		}
+		return false
+	case AddressPortDependent:
+		return c.sent[from]
+	default:
+		return true
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	server := startServer(t)
+
+	tests := []struct {
+		filtering string
+		want      string
+	}{
+		{EndpointIndependent, NATFullCone},
+		{AddressDependent, NATRestrictedCone},
+		{AddressPortDependent, NATPortRestrictedCone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filtering, func(t *testing.T) {
+			udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer udp.Close()
+			conn := &filteringConn{PacketConn: udp, filtering: tt.filtering, sent: make(map[netip.AddrPort]bool)}
+
+			result, err := Discover(context.Background(), conn, server.PrimaryAddr(), 200*time.Millisecond)
+			if err != nil {
+				t.Fatalf("Discover() error: %v", err)
+			}
+
+			// Without a real NAT the mapped address is the local socket
+			if local := udp.LocalAddr().(*net.UDPAddr).AddrPort(); result.MappedAddress != local {
+				t.Errorf("MappedAddress = %s, want %s", result.MappedAddress, local)
+			}
+			if result.Mapping != EndpointIndependent {
+				t.Errorf("Mapping = %q, want %q", result.Mapping, EndpointIndependent)
+			}
+			if result.Filtering != tt.filtering {
+				t.Errorf("Filtering = %q, want %q", result.Filtering, tt.filtering)
+			}
+			if result.NATType != tt.want {
+				t.Errorf("NATType = %q, want %q", result.NATType, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverNoResponse(t *testing.T) {
+	// A bound socket that never answers
+	silent, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	target := silent.LocalAddr().(*net.UDPAddr).AddrPort()
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		ctx     time.Duration
+		wantErr error
+		maxTime time.Duration
+	}{
+		{"request timeout", 50 * time.Millisecond, time.Minute, ErrNoResponse, time.Second},
+		{"context deadline", time.Minute, 100 * time.Millisecond, context.DeadlineExceeded, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.ctx)
+			defer cancel()
+
+			start := time.Now()
+			_, err = Discover(ctx, conn, target, tt.timeout)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Discover() error = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > tt.maxTime {
+				t.Errorf("Discover() took %s, want at most %s", elapsed, tt.maxTime)
+			}
+		})
+	}
+}
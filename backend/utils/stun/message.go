@@ -0,0 +1,209 @@
+// Package stun implements the subset of STUN (RFC 5389) needed for NAT
+// behavior discovery (RFC 5780): a client that classifies NAT mapping and
+// filtering over any net.PacketConn, and a small responder to test against.
+package stun
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net/netip"
+)
+
+// Message types
+const (
+	typeBindingRequest  uint16 = 0x0001
+	typeBindingResponse uint16 = 0x0101
+)
+
+// Attribute types
+const (
+	attrMappedAddress    uint16 = 0x0001
+	attrChangeRequest    uint16 = 0x0003
+	attrChangedAddress   uint16 = 0x0005 // RFC 3489 predecessor of OTHER-ADDRESS
+	attrXORMappedAddress uint16 = 0x0020
+	attrResponseOrigin   uint16 = 0x802b
+	attrOtherAddress     uint16 = 0x802c
+)
+
+// CHANGE-REQUEST flags
+const (
+	changeIP   uint32 = 0x04
+	changePort uint32 = 0x02
+)
+
+const (
+	magicCookie uint32 = 0x2112a442
+	headerSize         = 20
+)
+
+var errMalformed = errors.New("malformed STUN message")
+
+// message is a decoded STUN message
+type message struct {
+	typ           uint16
+	transactionID [12]byte
+
+	mapped        netip.AddrPort
+	other         netip.AddrPort
+	origin        netip.AddrPort
+	changeRequest uint32
+}
+
+// newTransactionID returns a random transaction ID
+func newTransactionID() [12]byte {
+	var id [12]byte
+	rand.Read(id[:])
+	return id
+}
+
+// encode serializes the message with the attributes that are set
+func (m *message) encode() []byte {
+	buf := make([]byte, headerSize, 128)
+	binary.BigEndian.PutUint16(buf[0:], m.typ)
+	binary.BigEndian.PutUint32(buf[4:], magicCookie)
+	copy(buf[8:], m.transactionID[:])
+
+	if m.changeRequest != 0 {
+		value := make([]byte, 4)
+		binary.BigEndian.PutUint32(value, m.changeRequest)
+		buf = appendAttribute(buf, attrChangeRequest, value)
+	}
+	if m.mapped.IsValid() {
+		buf = appendAttribute(buf, attrXORMappedAddress, encodeAddress(m.mapped, m.transactionID, true))
+		buf = appendAttribute(buf, attrMappedAddress, encodeAddress(m.mapped, m.transactionID, false))
+	}
+	if m.origin.IsValid() {
+		buf = appendAttribute(buf, attrResponseOrigin, encodeAddress(m.origin, m.transactionID, false))
+	}
+	if m.other.IsValid() {
+		buf = appendAttribute(buf, attrOtherAddress, encodeAddress(m.other, m.transactionID, false))
+	}
+
+	binary.BigEndian.PutUint16(buf[2:], uint16(len(buf)-headerSize))
+	return buf
+}
+
+// decodeMessage parses a STUN message, ignoring unknown attributes
+func decodeMessage(data []byte) (*message, error) {
+	if len(data) < headerSize || data[0]&0xc0 != 0 || binary.BigEndian.Uint32(data[4:]) != magicCookie {
+		return nil, errMalformed
+	}
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if headerSize+length > len(data) {
+		return nil, errMalformed
+	}
+
+	m := &message{typ: binary.BigEndian.Uint16(data[0:])}
+	copy(m.transactionID[:], data[8:20])
+
+	var xorMapped, mapped, other, changed netip.AddrPort
+	attrs := data[headerSize : headerSize+length]
+	for len(attrs) >= 4 {
+		typ := binary.BigEndian.Uint16(attrs[0:])
+		size := int(binary.BigEndian.Uint16(attrs[2:]))
+		if 4+size > len(attrs) {
+			return nil, errMalformed
+		}
+		value := attrs[4 : 4+size]
+
+		switch typ {
+		case attrXORMappedAddress:
+			xorMapped, _ = decodeAddress(value, m.transactionID, true)
+		case attrMappedAddress:
+			mapped, _ = decodeAddress(value, m.transactionID, false)
+		case attrOtherAddress:
+			other, _ = decodeAddress(value, m.transactionID, false)
+		case attrChangedAddress:
+			changed, _ = decodeAddress(value, m.transactionID, false)
+		case attrResponseOrigin:
+			m.origin, _ = decodeAddress(value, m.transactionID, false)
+		case attrChangeRequest:
+			if size == 4 {
+				m.changeRequest = binary.BigEndian.Uint32(value)
+			}
+		}
+
+		padded := (size + 3) &^ 3
+		if 4+padded > len(attrs) {
+			break
+		}
+		attrs = attrs[4+padded:]
+	}
+
+	m.mapped = xorMapped
+	if !m.mapped.IsValid() {
+		m.mapped = mapped
+	}
+	m.other = other
+	if !m.other.IsValid() {
+		m.other = changed
+	}
+	return m, nil
+}
+
+// appendAttribute appends a TLV attribute padded to a 4-byte boundary
+func appendAttribute(buf []byte, typ uint16, value []byte) []byte {
+	var header [4]byte
+	binary.BigEndian.PutUint16(header[0:], typ)
+	binary.BigEndian.PutUint16(header[2:], uint16(len(value)))
+	buf = append(buf, header[:]...)
+	buf = append(buf, value...)
+	for len(buf)%4 != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// encodeAddress encodes a (XOR-)MAPPED-ADDRESS style attribute value
+func encodeAddress(addr netip.AddrPort, transactionID [12]byte, xor bool) []byte {
+	ip := addr.Addr().Unmap()
+	family, raw := byte(0x01), ip.AsSlice()
+	if ip.Is6() {
+		family = 0x02
+	}
+
+	value := make([]byte, 4+len(raw))
+	value[1] = family
+	binary.BigEndian.PutUint16(value[2:], addr.Port())
+	copy(value[4:], raw)
+	if xor {
+		xorAddress(value, transactionID)
+	}
+	return value
+}
+
+// decodeAddress decodes a (XOR-)MAPPED-ADDRESS style attribute value
+func decodeAddress(value []byte, transactionID [12]byte, xor bool) (netip.AddrPort, error) {
+	if len(value) < 8 {
+		return netip.AddrPort{}, errMalformed
+	}
+	value = append([]byte(nil), value...)
+	if xor {
+		xorAddress(value, transactionID)
+	}
+
+	var ip netip.Addr
+	switch {
+	case value[1] == 0x01 && len(value) >= 8:
+		ip = netip.AddrFrom4([4]byte(value[4:8]))
+	case value[1] == 0x02 && len(value) >= 20:
+		ip = netip.AddrFrom16([16]byte(value[4:20]))
+	default:
+		return netip.AddrPort{}, errMalformed
+	}
+	return netip.AddrPortFrom(ip, binary.BigEndian.Uint16(value[2:])), nil
+}
+
+// xorAddress applies the XOR-MAPPED-ADDRESS obfuscation in place
+func xorAddress(value []byte, transactionID [12]byte) {
+	var key [16]byte
+	binary.BigEndian.PutUint32(key[0:], magicCookie)
+	copy(key[4:], transactionID[:])
+
+	value[2] ^= key[0]
+	value[3] ^= key[1]
+	for i := 4; i < len(value) && i-4 < len(key); i++ {
+		value[i] ^= key[i-4]
+	}
+}
@@ -0,0 +1,180 @@
+package stun
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// RFC 5769 section 2.2/2.3 transaction ID
+var vectorTransactionID = [12]byte{0xb7, 0xe7, 0xa7, 0x01, 0xbc, 0x34, 0xd6, 0x86, 0xfa, 0x87, 0xdf, 0xae}
+
+// decodeHex parses a hex dump that may contain spaces and newlines
+func decodeHex(t *testing.T, dump string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(strings.Join(strings.Fields(dump), ""))
+	if err != nil {
+		t.Fatalf("invalid hex dump: %v", err)
+	}
+	return data
+}
+
+func TestDecodeMessageVectors(t *testing.T) {
+	// RFC 5769 sample responses, trimmed after XOR-MAPPED-ADDRESS; the
+	// SOFTWARE attribute is kept to check that unknown attributes are skipped
+	tests := []struct {
+		name   string
+		packet string
+		want   netip.AddrPort
+	}{
+		{
+			name: "IPv4 XOR-MAPPED-ADDRESS",
+			packet: `
+				0101 001c 2112a442 b7e7a701 bc34d686 fa87dfae
+				8022 000b 74657374 20766563 746f7220
+				0020 0008 0001a147 e112a643`,
+			want: netip.MustParseAddrPort("192.0.2.1:32853"),
+		},
+		{
+			name: "IPv6 XOR-MAPPED-ADDRESS",
+			packet: `
+				0101 0028 2112a442 b7e7a701 bc34d686 fa87dfae
+				8022 000b 74657374 20766563 746f7220
+				0020 0014 0002a147 0113a9fa a5d3f179 bc25f4b5 bed2b9d9`,
+			want: netip.MustParseAddrPort("[2001:db8:1234:5678:11:2233:4455:6677]:32853"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := decodeMessage(decodeHex(t, tt.packet))
+			if err != nil {
+				t.Fatalf("decodeMessage() error: %v", err)
+			}
+			if m.typ != typeBindingResponse {
+				t.Errorf("type = %#04x, want %#04x", m.typ, typeBindingResponse)
+			}
+			if m.transactionID != vectorTransactionID {
+				t.Errorf("transaction ID = %x, want %x", m.transactionID, vectorTransactionID)
+			}
+			if m.mapped != tt.want {
+				t.Errorf("mapped = %s, want %s", m.mapped, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  message
+	}{
+		{
+			name: "binding request",
+			msg:  message{typ: typeBindingRequest},
+		},
+		{
+			name: "change request",
+			msg:  message{typ: typeBindingRequest, changeRequest: changeIP | changePort},
+		},
+		{
+			name: "IPv4 response",
+			msg: message{
+				typ:    typeBindingResponse,
+				mapped: netip.MustParseAddrPort("203.0.113.7:40000"),
+				origin: netip.MustParseAddrPort("198.51.100.1:3478"),
+				other:  netip.MustParseAddrPort("198.51.100.2:3479"),
+			},
+		},
+		{
+			name: "IPv6 response",
+			msg: message{
+				typ:    typeBindingResponse,
+				mapped: netip.MustParseAddrPort("[2001:db8::7]:40000"),
+				origin: netip.MustParseAddrPort("[2001:db8::1]:3478"),
+				other:  netip.MustParseAddrPort("[2001:db8::2]:3479"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg
+			msg.transactionID = newTransactionID()
+
+			packet := msg.encode()
+			if len(packet)%4 != 0 {
+				t.Errorf("encoded length %d is not a multiple of 4", len(packet))
+			}
+
+			got, err := decodeMessage(packet)
+			if err != nil {
+				t.Fatalf("decodeMessage() error: %v", err)
+			}
+			if *got != msg {
+				t.Errorf("round trip = %+v, want %+v", *got, msg)
+			}
+		})
+	}
+}
+
+func TestDecodeMessageFallbacks(t *testing.T) {
+	id := newTransactionID()
+	mapped := netip.MustParseAddrPort("203.0.113.7:40000")
+	changed := netip.MustParseAddrPort("198.51.100.2:3479")
+
+	// RFC 3489 servers send only MAPPED-ADDRESS and CHANGED-ADDRESS
+	packet := make([]byte, headerSize)
+	binary.BigEndian.PutUint16(packet[0:], typeBindingResponse)
+	binary.BigEndian.PutUint32(packet[4:], magicCookie)
+	copy(packet[8:], id[:])
+	packet = appendAttribute(packet, attrMappedAddress, encodeAddress(mapped, id, false))
+	packet = appendAttribute(packet, attrChangedAddress, encodeAddress(changed, id, false))
+	binary.BigEndian.PutUint16(packet[2:], uint16(len(packet)-headerSize))
+
+	m, err := decodeMessage(packet)
+	if err != nil {
+		t.Fatalf("decodeMessage() error: %v", err)
+	}
+	if m.mapped != mapped {
+		t.Errorf("mapped = %s, want %s", m.mapped, mapped)
+	}
+	if m.other != changed {
+		t.Errorf("other = %s, want %s", m.other, changed)
+	}
+}
+
+func TestDecodeMessageMalformed(t *testing.T) {
+	valid := (&message{typ: typeBindingResponse, mapped: netip.MustParseAddrPort("192.0.2.1:1")}).encode()
+
+	badCookie := append([]byte(nil), valid...)
+	badCookie[4] ^= 0xff
+
+	badLength := append([]byte(nil), valid...)
+	badLength[3] += 4
+
+	badAttribute := append([]byte(nil), valid...)
+	badAttribute[headerSize+3] = 0xff
+
+	tests := []struct {
+		name   string
+		packet []byte
+	}{
+		{"empty", nil},
+		{"short header", valid[:headerSize-1]},
+		{"not STUN", append([]byte{0xc0}, valid[1:]...)},
+		{"bad magic cookie", badCookie},
+		{"length past end", badLength},
+		{"attribute past end", badAttribute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeMessage(tt.packet); err == nil {
+				t.Error("decodeMessage() succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package stun
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+)
+
+// Default STUN ports
+const (
+	DefaultPort          = 3478
+	DefaultAlternatePort = 3479
+)
+
+// Server is a minimal RFC 5780 STUN responder. It listens on every
+// combination of two IPs and two ports so clients can run the full set of
+// mapping and filtering tests; on a single host the loopback addresses
+// 127.0.0.1 and 127.0.0.2 are enough.
+type Server struct {
+	conns map[netip.AddrPort]*net.UDPConn
+	ips   [2]netip.Addr
+	ports [2]uint16
+	wg    sync.WaitGroup
+}
+
+// NewServer binds the four sockets of a STUN responder
+func NewServer(primaryIP, alternateIP netip.Addr, primaryPort, alternatePort uint16) (*Server, error) {
+	if primaryIP == alternateIP || primaryPort == alternatePort {
+		return nil, errors.New("STUN server needs two distinct IPs and two distinct ports")
+	}
+
+	s := &Server{
+		conns: make(map[netip.AddrPort]*net.UDPConn, 4),
+		ips:   [2]netip.Addr{primaryIP, alternateIP},
+		ports: [2]uint16{primaryPort, alternatePort},
+	}
+	for _, ip := range s.ips {
+		for _, port := range s.ports {
+			addr := netip.AddrPortFrom(ip, port)
+			conn, err := net.ListenUDP("udp", net.UDPAddrFromAddrPort(addr))
+			if err != nil {
+				s.Close()
+				return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+			}
+			s.conns[addr] = conn
+		}
+	}
+	return s, nil
+}
+
+// Serve answers binding requests until Close is called
+func (s *Server) Serve() {
+	for addr, conn := range s.conns {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(addr, conn)
+		}()
+	}
+	s.wg.Wait()
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	var errs []error
+	for _, conn := range s.conns {
+		errs = append(errs, conn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// PrimaryAddr returns the address clients should send their first request to
+func (s *Server) PrimaryAddr() netip.AddrPort {
+	return netip.AddrPortFrom(s.ips[0], s.ports[0])
+}
+
+// serveConn answers requests received on one socket
+func (s *Server) serveConn(local netip.AddrPort, conn *net.UDPConn) {
+	buf := make([]byte, 1500)
+	for {
+		n, remote, err := conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		request, err := decodeMessage(buf[:n])
+		if err != nil || request.typ != typeBindingRequest {
+			continue
+		}
+
+		// Respond from the socket selected by CHANGE-REQUEST
+		ip, port := local.Addr(), local.Port()
+		if request.changeRequest&changeIP != 0 {
+			ip = s.otherIP(ip)
+		}
+		if request.changeRequest&changePort != 0 {
+			port = s.otherPort(port)
+		}
+		origin := netip.AddrPortFrom(ip, port)
+
+		response := &message{
+			typ:           typeBindingResponse,
+			transactionID: request.transactionID,
+			mapped:        netip.AddrPortFrom(remote.Addr().Unmap(), remote.Port()),
+			origin:        origin,
+			other:         netip.AddrPortFrom(s.otherIP(local.Addr()), s.otherPort(local.Port())),
+		}
+		s.conns[origin].WriteToUDPAddrPort(response.encode(), remote)
+	}
+}
+
+// otherIP returns the server IP that is not ip
+func (s *Server) otherIP(ip netip.Addr) netip.Addr {
+	if ip == s.ips[0] {
+		return s.ips[1]
+	}
+	return s.ips[0]
+}
+
+// otherPort returns the server port that is not port
+func (s *Server) otherPort(port uint16) uint16 {
+	if port == s.ports[0] {
+		return s.ports[1]
+	}
+	return s.ports[0]
+}
@@ -33,11 +33,13 @@ const (
 	MessageTypeUploadStart    MessageType = "upload_start"
 	MessageTypeUploadResult   MessageType = "upload_result"
 	MessageTypeProxySkipped   MessageType = "proxy_skipped"
+	MessageTypePhaseProgress  MessageType = "phase_progress"
 )
 
 // WebSocketMessage represents a message sent via WebSocket
 type WebSocketMessage struct {
 	Type      MessageType `json:"type"`
+	TaskID    string      `json:"task_id,omitempty"`
 	Timestamp time.Time   `json:"timestamp"`
 	Data      any         `json:"data"`
 }
@@ -53,6 +55,8 @@ type TestStartData struct {
 		UploadSize       int     `json:"upload_size"`
 		Timeout          int     `json:"timeout"`
 		Concurrent       int     `json:"concurrent"`
+		NodeConcurrent   int     `json:"node_concurrent"`
+		MaxSpeedTests    int     `json:"max_speed_tests"`
 		MaxLatency       int     `json:"max_latency"`
 		MinDownloadSpeed float64 `json:"min_download_speed"`
 		MinUploadSpeed   float64 `json:"min_upload_speed"`
@@ -73,6 +77,16 @@ type TestProgressData struct {
 	EstimatedTime int     `json:"estimated_time,omitempty"` // 预计剩余时间(秒)
 }
 
+// PhaseProgressData contains pipeline phase progress (screening / bandwidth)
+type PhaseProgressData struct {
+	Phase              string `json:"phase"` // "screening" or "bandwidth"
+	CurrentProxy       string `json:"current_proxy"`
+	ScreeningCompleted int    `json:"screening_completed"`
+	ScreeningTotal     int    `json:"screening_total"`
+	BandwidthCompleted int    `json:"bandwidth_completed"`
+	BandwidthTotal     int    `json:"bandwidth_total"`
+}
+
 // LatencyTestData contains latency test specific data
 type LatencyTestData struct {
 	ProxyName      string  `json:"proxy_name"`
@@ -267,8 +281,14 @@ func (h *Hub) Run() {
 
 // BroadcastMessage sends a message to all connected clients
 func (h *Hub) BroadcastMessage(msgType MessageType, data any) {
+	h.BroadcastTaskMessage("", msgType, data)
+}
+
+// BroadcastTaskMessage sends a message tagged with the originating task ID to all connected clients
+func (h *Hub) BroadcastTaskMessage(taskID string, msgType MessageType, data any) {
 	message := WebSocketMessage{
 		Type:      msgType,
+		TaskID:    taskID,
 		Timestamp: time.Now(),
 		Data:      data,
 	}
@@ -281,6 +301,7 @@ func (h *Hub) BroadcastMessage(msgType MessageType, data any) {
 
 	logger.Logger.Debug("Broadcasting WebSocket message",
 		slog.String("message_type", string(msgType)),
+		slog.String("task_id", taskID),
 		slog.Int("clients_count", len(h.clients)),
 	)
 